@@ -0,0 +1,336 @@
+package neuro
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Activation is a neuron transfer function. Deriv receives both the
+// weighted sum x and the already computed output y = Func(x), so cheap
+// forms like y*(1-y) can be used.
+type Activation interface {
+	Name() string
+	Func(x float64) float64
+	Deriv(x, y float64) float64
+}
+
+type Sigmoid struct{}
+
+func (Sigmoid) Name() string               { return "sigmoid" }
+func (Sigmoid) Func(x float64) float64     { return 1.0 / (1.0 + math.Exp(-x)) }
+func (Sigmoid) Deriv(x, y float64) float64 { return y * (1 - y) }
+
+type Tanh struct{}
+
+func (Tanh) Name() string               { return "tanh" }
+func (Tanh) Func(x float64) float64     { return math.Tanh(x) }
+func (Tanh) Deriv(x, y float64) float64 { return 1 - y*y }
+
+type ReLU struct{}
+
+func (ReLU) Name() string { return "relu" }
+
+func (ReLU) Func(x float64) float64 {
+	if x > 0 {
+		return x
+	}
+	return 0
+}
+
+func (ReLU) Deriv(x, y float64) float64 {
+	if x > 0 {
+		return 1
+	}
+	return 0
+}
+
+type LeakyReLU struct {
+	Alpha float64
+}
+
+func (a LeakyReLU) Name() string { return "leaky_relu:" + strconv.FormatFloat(a.Alpha, 'g', -1, 64) }
+
+func (a LeakyReLU) Func(x float64) float64 {
+	if x > 0 {
+		return x
+	}
+	return a.Alpha * x
+}
+
+func (a LeakyReLU) Deriv(x, y float64) float64 {
+	if x > 0 {
+		return 1
+	}
+	return a.Alpha
+}
+
+type ELU struct {
+	Alpha float64
+}
+
+func (a ELU) Name() string { return "elu:" + strconv.FormatFloat(a.Alpha, 'g', -1, 64) }
+
+func (a ELU) Func(x float64) float64 {
+	if x > 0 {
+		return x
+	}
+	return a.Alpha * (math.Exp(x) - 1)
+}
+
+func (a ELU) Deriv(x, y float64) float64 {
+	if x > 0 {
+		return 1
+	}
+	return y + a.Alpha
+}
+
+type Softplus struct{}
+
+func (Softplus) Name() string { return "softplus" }
+
+func (Softplus) Func(x float64) float64 {
+	if x > 30 {
+		return x
+	}
+	return math.Log1p(math.Exp(x))
+}
+
+func (Softplus) Deriv(x, y float64) float64 { return 1.0 / (1.0 + math.Exp(-x)) }
+
+// Softmax normalises the whole output layer into probabilities. Func and
+// Deriv only give the element-wise exp and the Jacobian diagonal; layers
+// apply the full softmax, so use it for the output layer.
+type Softmax struct{}
+
+func (Softmax) Name() string               { return "softmax" }
+func (Softmax) Func(x float64) float64     { return math.Exp(x) }
+func (Softmax) Deriv(x, y float64) float64 { return y * (1 - y) }
+
+func softmax(sum, out []float64) {
+	max := math.Inf(-1)
+	for _, s := range sum {
+		max = math.Max(max, s)
+	}
+	var total float64
+	for i, s := range sum {
+		out[i] = math.Exp(s - max)
+		total += out[i]
+	}
+	for i := range out {
+		out[i] /= total
+	}
+}
+
+type Identity struct{}
+
+func (Identity) Name() string               { return "identity" }
+func (Identity) Func(x float64) float64     { return x }
+func (Identity) Deriv(x, y float64) float64 { return 1 }
+
+var (
+	actMtx     sync.RWMutex
+	customActs = map[string]Activation{}
+)
+
+// RegisterActivation makes a custom activation known to ActivationByName
+// under its Name(), so nets using it can be saved and loaded again. It has
+// to be registered before LoadNet, registering a name again replaces it.
+// It panics on the name of a built-in activation.
+func RegisterActivation(act Activation) {
+	name := act.Name()
+	if builtin, _ := builtinActivation(name); builtin != nil || name == "" {
+		panic(fmt.Sprintf("neuro: activation name %q is taken", name))
+	}
+	actMtx.Lock()
+	customActs[name] = act
+	actMtx.Unlock()
+}
+
+func ActivationByName(name string) (Activation, error) {
+	act, err := builtinActivation(name)
+	if act != nil || err != nil {
+		return act, err
+	}
+	actMtx.RLock()
+	act, ok := customActs[name]
+	actMtx.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown activation %q", name)
+	}
+	return act, nil
+}
+
+// builtinActivation returns nil and no error for names it does not know.
+func builtinActivation(name string) (Activation, error) {
+	base, param := name, ""
+	if i := strings.Index(name, ":"); i >= 0 {
+		base, param = name[:i], name[i+1:]
+	}
+	alpha := func(def float64) (float64, error) {
+		if param == "" {
+			return def, nil
+		}
+		return strconv.ParseFloat(param, 64)
+	}
+	switch base {
+	case "sigmoid":
+		return Sigmoid{}, nil
+	case "tanh":
+		return Tanh{}, nil
+	case "relu":
+		return ReLU{}, nil
+	case "leaky_relu":
+		a, err := alpha(0.01)
+		if err != nil {
+			return nil, err
+		}
+		return LeakyReLU{Alpha: a}, nil
+	case "elu":
+		a, err := alpha(1)
+		if err != nil {
+			return nil, err
+		}
+		return ELU{Alpha: a}, nil
+	case "softplus":
+		return Softplus{}, nil
+	case "softmax":
+		return Softmax{}, nil
+	case "identity":
+		return Identity{}, nil
+	case "":
+		return nil, errors.New("empty activation name")
+	}
+	return nil, nil
+}
+
+// setActivations expands the activations given to CreateNet into one per
+// non-input layer: a single value applies to every hidden layer, Layers
+// values cover the hidden layers and Layers+1 values also set the output.
+// Nets built from layer specs take hidden activations from the specs, so
+// there a single value is the output activation. The values are kept in
+// n.acts, n.Activations holds their names for saving.
+func (n *NetPerc) setActivations(acts []Activation) {
+	n.acts = make([]Activation, n.Layers+1)
+	for i := range n.acts {
+		n.acts[i] = n.defaultAct(i == n.Layers)
+	}
+	switch {
+	case len(acts) == 1 && len(n.Specs) > 0:
+		n.acts[n.Layers] = acts[0]
+	case len(acts) == 1:
+		for i := 0; i < n.Layers; i++ {
+			n.acts[i] = acts[0]
+		}
+	case len(acts) > 1:
+		for i := 0; i < len(acts) && i <= n.Layers; i++ {
+			n.acts[i] = acts[i]
+		}
+	}
+	n.Activations = make([]string, len(n.acts))
+	for i, act := range n.acts {
+		n.Activations[i] = act.Name()
+	}
+}
+
+// setOutputAct follows a change of FinalAct on a net that was already
+// created.
+func (n *NetPerc) setOutputAct() {
+	if len(n.Activations) == 0 {
+		return
+	}
+	act := n.defaultAct(true)
+	n.Activations[len(n.Activations)-1] = act.Name()
+	if len(n.acts) == len(n.Activations) {
+		n.acts[len(n.acts)-1] = act
+	}
+}
+
+func (n *NetPerc) defaultAct(final bool) Activation {
+	if final && n.Softmax {
+		return Softmax{}
+	}
+	if final && !n.FinalAct {
+		return Identity{}
+	}
+	return Sigmoid{}
+}
+
+func (n *NetPerc) checkActivations() error {
+	for _, name := range n.Activations {
+		if _, err := ActivationByName(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkNames makes sure the activations and the loss of n can be found by
+// name again, so Save does not write a file that LoadNet rejects.
+func (n *NetPerc) checkNames() error {
+	if err := n.checkActivations(); err != nil {
+		return fmt.Errorf("%v, see RegisterActivation", err)
+	}
+	if n.LossName != "" {
+		if _, err := LossByName(n.LossName); err != nil {
+			return fmt.Errorf("%v, see RegisterLoss", err)
+		}
+	}
+	return nil
+}
+
+// layerAct returns the activation of n.Dense[il]. Loaded nets only have
+// the names and resolve them here, dumps written before activations were
+// stored fall back to sigmoid hidden layers and a FinalAct controlled
+// output.
+func (n *NetPerc) layerAct(il int) Activation {
+	if len(n.acts) != len(n.Dense) {
+		acts := make([]Activation, len(n.Dense))
+		for i := range acts {
+			acts[i] = n.defaultAct(i == len(acts)-1)
+			if i < len(n.Activations) {
+				if act, err := ActivationByName(n.Activations[i]); err == nil {
+					acts[i] = act
+				}
+			}
+		}
+		n.acts = acts
+	}
+	return n.acts[il]
+}
+
+func (sp LayerSpec) MarshalJSON() ([]byte, error) {
+	type spec LayerSpec
+	var act string
+	if sp.Act != nil {
+		act = sp.Act.Name()
+	}
+	return json.Marshal(struct {
+		spec
+		Act string `json:"act,omitempty"`
+	}{spec(sp), act})
+}
+
+func (sp *LayerSpec) UnmarshalJSON(bts []byte) error {
+	type spec LayerSpec
+	var raw struct {
+		spec
+		Act string `json:"act"`
+	}
+	if err := json.Unmarshal(bts, &raw); err != nil {
+		return err
+	}
+	*sp = LayerSpec(raw.spec)
+	if raw.Act != "" {
+		act, err := ActivationByName(raw.Act)
+		if err != nil {
+			return err
+		}
+		sp.Act = act
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package neuro
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type square struct{}
+
+func (square) Name() string               { return "square" }
+func (square) Func(x float64) float64     { return x * x }
+func (square) Deriv(x, y float64) float64 { return 2 * x }
+
+func TestCustomActivation(t *testing.T) {
+	nets := map[string]*NetPerc{
+		"create_net": InitNetPerc(2, 3).CreateNet(xorData, 1, square{}, square{}, square{}),
+		"layer_spec": InitNetPercLayers(
+			LayerSpec{Neurons: 3, Act: square{}},
+			LayerSpec{Neurons: 3, Act: square{}},
+		).CreateNet(xorData, 1, square{}),
+	}
+	for name, n := range nets {
+		for _, net := range []*NetPerc{n, n.Copy()} {
+			for il := range net.Dense {
+				if _, ok := net.layerAct(il).(square); !ok {
+					t.Errorf("%s: layer %d uses %s", name, il, net.layerAct(il).Name())
+				}
+			}
+		}
+	}
+}
+
+func TestSetFinActAfterCreate(t *testing.T) {
+	n := InitNetPerc(1, 3).CreateNet(xorData, 1)
+	out := len(n.Dense) - 1
+	if _, ok := n.layerAct(out).(Sigmoid); !ok {
+		t.Fatalf("output is %s, want sigmoid", n.layerAct(out).Name())
+	}
+	n.SetFinAct(false)
+	if _, ok := n.layerAct(out).(Identity); !ok || n.Activations[1] != "identity" {
+		t.Fatalf("output is %s after SetFinAct(false)", n.layerAct(out).Name())
+	}
+	n.SetFinAct(true)
+	if _, ok := n.layerAct(out).(Sigmoid); !ok || n.Activations[1] != "sigmoid" {
+		t.Fatalf("output is %s after SetFinAct(true)", n.layerAct(out).Name())
+	}
+
+	tanh := InitNetPerc(1, 3).CreateNet(xorData, 1, Tanh{}, Tanh{})
+	tanh.SetFinAct(true)
+	if _, ok := tanh.layerAct(out).(Tanh); !ok {
+		t.Fatal("SetFinAct without a change replaced the output activation")
+	}
+}
+
+type cube struct{}
+
+func (cube) Name() string               { return "cube" }
+func (cube) Func(x float64) float64     { return x * x * x }
+func (cube) Deriv(x, y float64) float64 { return 3 * x * x }
+
+func TestSaveCustomActivation(t *testing.T) {
+	dir := t.TempDir()
+	unknown := InitNetPerc(1, 3).CreateNet(xorData, 1, cube{})
+	if err := unknown.Save(filepath.Join(dir, "cube.json")); err == nil {
+		t.Fatal("saved a net with an unregistered activation")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cube.json")); !os.IsNotExist(err) {
+		t.Fatalf("file left behind: %v", err)
+	}
+
+	RegisterActivation(square{})
+	n := InitNetPerc(1, 3).SetSeed(1).SetWeight(-1, 1).CreateNet(xorData, 1, square{})
+	file := filepath.Join(dir, "square.json")
+	if err := n.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadNet(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.layerAct(0).(square); !ok {
+		t.Fatalf("loaded net uses %s", loaded.layerAct(0).Name())
+	}
+	want, got := n.forward(xorData[1].Inputs), loaded.forward(xorData[1].Inputs)
+	if !sameFloats([][]float64{want}, [][]float64{got}) {
+		t.Fatalf("loaded net predicts %v, want %v", got, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registered a built-in name")
+		}
+	}()
+	RegisterActivation(fakeSigmoid{})
+}
+
+type fakeSigmoid struct{ square }
+
+func (fakeSigmoid) Name() string { return "sigmoid" }
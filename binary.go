@@ -0,0 +1,380 @@
+package neuro
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// binaryMagic starts every binary model. It is followed by the format
+// version, a flags byte and the body, which is gzipped with binaryGzip.
+// The body holds a length prefixed JSON head, the netFile without its
+// weights, the little-endian weights of every layer and finally the SHA-256
+// of everything before it, taken before compression.
+var binaryMagic = []byte("NRO\x00")
+
+const (
+	binaryFloat32 = 1 << iota
+	binaryGzip
+)
+
+// Limits for the sizes a stream claims before its checksum can be verified.
+const (
+	maxBinaryHead   = 1 << 26
+	maxLayerWeights = 1 << 28
+	maxLayerSlots   = 4
+)
+
+// BinaryOptions controls WriteBinary. Float32 halves the size of the
+// weights at the cost of precision, Gzip compresses the whole body.
+type BinaryOptions struct {
+	Float32 bool
+	Gzip    bool
+}
+
+type binaryHead struct {
+	netFile
+	Shapes []binaryShape `json:"shapes"`
+}
+
+type binaryShape struct {
+	In     int  `json:"in"`
+	Out    int  `json:"out"`
+	Bias   bool `json:"bias"`
+	SlotsW int  `json:"slots_w"`
+	SlotsB int  `json:"slots_b"`
+}
+
+func (n *NetPerc) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := n.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (n *NetPerc) UnmarshalBinary(data []byte) error {
+	_, err := n.ReadFrom(bytes.NewReader(data))
+	return err
+}
+
+// WriteTo writes the net with float64 weights and no compression.
+func (n *NetPerc) WriteTo(w io.Writer) (int64, error) {
+	return n.WriteBinary(w, BinaryOptions{})
+}
+
+func (n *NetPerc) WriteBinary(w io.Writer, opts BinaryOptions) (int64, error) {
+	if err := n.checkNames(); err != nil {
+		return 0, err
+	}
+	cw := &countWriter{w: w}
+	var flags byte
+	if opts.Float32 {
+		flags |= binaryFloat32
+	}
+	if opts.Gzip {
+		flags |= binaryGzip
+	}
+	header := append(append([]byte(nil), binaryMagic...), FormatVersion, flags)
+	if _, err := cw.Write(header); err != nil {
+		return cw.n, err
+	}
+	hash := sha256.New()
+	hash.Write(header)
+	var (
+		body io.Writer = cw
+		zw   *gzip.Writer
+	)
+	if opts.Gzip {
+		zw = gzip.NewWriter(cw)
+		body = zw
+	}
+	bw := bufio.NewWriter(body)
+	if err := n.writeBody(io.MultiWriter(bw, hash), opts.Float32); err != nil {
+		return cw.n, err
+	}
+	if _, err := bw.Write(hash.Sum(nil)); err != nil {
+		return cw.n, err
+	}
+	if err := bw.Flush(); err != nil {
+		return cw.n, err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return cw.n, err
+		}
+	}
+	return cw.n, nil
+}
+
+func (n *NetPerc) writeBody(w io.Writer, f32 bool) error {
+	head := binaryHead{netFile: *n.file()}
+	head.Weights = nil
+	for _, l := range n.Dense {
+		head.Shapes = append(head.Shapes, binaryShape{
+			In:     l.In,
+			Out:    l.Out,
+			Bias:   l.Bias,
+			SlotsW: len(l.OptW),
+			SlotsB: len(l.OptB),
+		})
+	}
+	bts, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(bts))); err != nil {
+		return err
+	}
+	if _, err := w.Write(bts); err != nil {
+		return err
+	}
+	for _, l := range n.Dense {
+		fls := [][]float64{l.Weights, l.Biases}
+		fls = append(fls, l.OptW...)
+		fls = append(fls, l.OptB...)
+		for _, f := range fls {
+			if err := writeFloats(w, f, f32); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReadFrom replaces n with the net read from r, the encoding options are
+// taken from the stream.
+func (n *NetPerc) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countReader{r: r}
+	head := make([]byte, len(binaryMagic)+2)
+	if _, err := io.ReadFull(cr, head); err != nil {
+		return cr.n, err
+	}
+	if !bytes.Equal(head[:len(binaryMagic)], binaryMagic) {
+		return cr.n, errors.New("not a binary net")
+	}
+	version, flags := head[len(binaryMagic)], head[len(binaryMagic)+1]
+	if version == 0 || int(version) > FormatVersion {
+		return cr.n, fmt.Errorf("unsupported format version %d", version)
+	}
+	var (
+		body io.Reader = cr
+		zr   *gzip.Reader
+	)
+	if flags&binaryGzip != 0 {
+		var err error
+		if zr, err = gzip.NewReader(body); err != nil {
+			return cr.n, err
+		}
+		zr.Multistream(false)
+		defer zr.Close()
+		body = zr
+	}
+	hash := sha256.New()
+	hash.Write(head)
+	f, err := readBody(io.TeeReader(body, hash), flags&binaryFloat32 != 0)
+	if err != nil {
+		return cr.n, err
+	}
+	if version >= checksumVersion {
+		sum := make([]byte, sha256.Size)
+		if _, err := io.ReadFull(body, sum); err != nil {
+			return cr.n, err
+		}
+		if !bytes.Equal(sum, hash.Sum(nil)) {
+			return cr.n, errors.New("checksum mismatch")
+		}
+	}
+	if zr != nil {
+		// reading up to EOF makes gzip check its own trailer
+		if _, err := io.Copy(ioutil.Discard, zr); err != nil {
+			return cr.n, err
+		}
+	}
+	net, err := f.load()
+	if err != nil {
+		return cr.n, err
+	}
+	*n = *net
+	return cr.n, nil
+}
+
+func readBody(r io.Reader, f32 bool) (*netFile, error) {
+	var size uint32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return nil, err
+	}
+	if size > maxBinaryHead {
+		return nil, fmt.Errorf("head of %d bytes", size)
+	}
+	bts, err := ioutil.ReadAll(io.LimitReader(r, int64(size)))
+	if err != nil {
+		return nil, err
+	}
+	if len(bts) != int(size) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	var head binaryHead
+	if err := json.Unmarshal(bts, &head); err != nil {
+		return nil, err
+	}
+	if err := head.checkShapes(); err != nil {
+		return nil, err
+	}
+	head.Weights = nil
+	for _, sh := range head.Shapes {
+		l := &Layer{In: sh.In, Out: sh.Out, Bias: sh.Bias}
+		var err error
+		if l.Weights, err = readFloats(r, sh.In*sh.Out, f32); err != nil {
+			return nil, err
+		}
+		if l.Biases, err = readFloats(r, sh.Out, f32); err != nil {
+			return nil, err
+		}
+		for s := 0; s < sh.SlotsW; s++ {
+			slot, err := readFloats(r, sh.In*sh.Out, f32)
+			if err != nil {
+				return nil, err
+			}
+			l.OptW = append(l.OptW, slot)
+		}
+		for s := 0; s < sh.SlotsB; s++ {
+			slot, err := readFloats(r, sh.Out, f32)
+			if err != nil {
+				return nil, err
+			}
+			l.OptB = append(l.OptB, slot)
+		}
+		head.Weights = append(head.Weights, l)
+	}
+	return &head.netFile, nil
+}
+
+// checkShapes makes sure the layers follow the architecture of the head
+// before any of them is read.
+func (h *binaryHead) checkShapes() error {
+	arch := h.Arch
+	hidden := arch.Layers
+	if len(arch.Specs) > 0 {
+		hidden = len(arch.Specs)
+	}
+	if len(h.Shapes) != hidden+1 {
+		return fmt.Errorf("%d layers for %d hidden layers", len(h.Shapes), hidden)
+	}
+	in := arch.Inps
+	for il, sh := range h.Shapes {
+		out := arch.Outs
+		if il < hidden {
+			out = arch.Neurons
+			if len(arch.Specs) > 0 {
+				out = arch.Specs[il].Neurons
+			}
+		}
+		switch {
+		case sh.In != in || sh.Out != out:
+			return fmt.Errorf("layer %d: %dx%d, architecture gives %dx%d", il, sh.In, sh.Out, in, out)
+		case sh.In < 1 || sh.Out < 1 || sh.In > maxLayerWeights/sh.Out:
+			return fmt.Errorf("layer %d: bad size %dx%d", il, sh.In, sh.Out)
+		case sh.SlotsW < 0 || sh.SlotsW > maxLayerSlots || sh.SlotsB < 0 || sh.SlotsB > maxLayerSlots:
+			return fmt.Errorf("layer %d: %d/%d optimizer slots", il, sh.SlotsW, sh.SlotsB)
+		}
+		in = out
+	}
+	return nil
+}
+
+func writeFloats(w io.Writer, fls []float64, f32 bool) error {
+	if !f32 {
+		return binary.Write(w, binary.LittleEndian, fls)
+	}
+	conv := make([]float32, len(fls))
+	for i, f := range fls {
+		conv[i] = float32(f)
+	}
+	return binary.Write(w, binary.LittleEndian, conv)
+}
+
+// readFloats reads in chunks, so a size from a damaged head runs into the
+// end of the stream before all of it is allocated.
+func readFloats(r io.Reader, size int, f32 bool) ([]float64, error) {
+	const chunk = 1 << 13
+	fls := make([]float64, 0, minInt(size, chunk))
+	var (
+		buf  []float64
+		conv []float32
+	)
+	if f32 {
+		conv = make([]float32, minInt(size, chunk))
+	} else {
+		buf = make([]float64, minInt(size, chunk))
+	}
+	for len(fls) < size {
+		k := minInt(size-len(fls), chunk)
+		if !f32 {
+			if err := binary.Read(r, binary.LittleEndian, buf[:k]); err != nil {
+				return nil, err
+			}
+			fls = append(fls, buf[:k]...)
+			continue
+		}
+		if err := binary.Read(r, binary.LittleEndian, conv[:k]); err != nil {
+			return nil, err
+		}
+		for _, f := range conv[:k] {
+			fls = append(fls, float64(f))
+		}
+	}
+	return fls, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// SaveBinary writes the net in the binary encoding as safely as Save,
+// LoadNet reads both encodings.
+func (n *NetPerc) SaveBinary(fileName string, opts ...BinaryOptions) error {
+	if fileName == "" {
+		return errors.New("empty filename")
+	}
+	var opt BinaryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	return writeFile(fileName, func(w io.Writer) error {
+		_, err := n.WriteBinary(w, opt)
+		return err
+	})
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
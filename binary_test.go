@@ -0,0 +1,193 @@
+package neuro
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"runtime"
+	"testing"
+)
+
+// trainedNet is a net with optimizer state after a few Adam steps.
+func trainedNet() *NetPerc {
+	n := InitNetPerc(2, 5).SetBias(true).SetWeight(-1, 1).SetOptimizer(Adam{}).
+		CreateNet(benchSet(8, 3, 2), 1)
+	n.TrainIters()
+	return n
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	nets := map[string]*NetPerc{
+		"plain":     InitNetPerc(1, 4).SetWeight(-1, 1).CreateNet(xorData, 1),
+		"optimizer": trainedNet(),
+	}
+	for name, n := range nets {
+		for _, opts := range []BinaryOptions{{}, {Float32: true}, {Gzip: true}, {Float32: true, Gzip: true}} {
+			var buf bytes.Buffer
+			written, err := n.WriteBinary(&buf, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			size := int64(buf.Len())
+			loaded := &NetPerc{}
+			read, err := loaded.ReadFrom(&buf)
+			if err != nil {
+				t.Fatalf("%s %+v: %v", name, opts, err)
+			}
+			if written != size || read != size {
+				t.Errorf("%s %+v: wrote %d and read %d of %d bytes", name, opts, written, read, size)
+			}
+			want := func(f float64) float64 {
+				if opts.Float32 {
+					return float64(float32(f))
+				}
+				return f
+			}
+			same := func(what string, got, fls []float64) {
+				if len(got) != len(fls) {
+					t.Fatalf("%s %+v: %d %s, want %d", name, opts, len(got), what, len(fls))
+				}
+				for k := range fls {
+					if got[k] != want(fls[k]) {
+						t.Fatalf("%s %+v: %s differ", name, opts, what)
+					}
+				}
+			}
+			for il, l := range n.Dense {
+				ll := loaded.Dense[il]
+				same("weights", ll.Weights, l.Weights)
+				same("biases", ll.Biases, l.Biases)
+				if len(ll.OptW) != len(l.OptW) || len(ll.OptB) != len(l.OptB) {
+					t.Fatalf("%s %+v: layer %d has %d/%d slots, want %d/%d", name, opts, il, len(ll.OptW), len(ll.OptB), len(l.OptW), len(l.OptB))
+				}
+				for s := range l.OptW {
+					same("weight slots", ll.OptW[s], l.OptW[s])
+				}
+				for s := range l.OptB {
+					same("bias slots", ll.OptB[s], l.OptB[s])
+				}
+			}
+			if loaded.Optim.Step != n.Optim.Step || loaded.optimizer().Name() != n.optimizer().Name() {
+				t.Errorf("%s %+v: optimizer %s at step %d, want %s at %d", name, opts,
+					loaded.optimizer().Name(), loaded.Optim.Step, n.optimizer().Name(), n.Optim.Step)
+			}
+		}
+	}
+}
+
+func TestBinaryRejects(t *testing.T) {
+	bts, err := trainedNet().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	flipped := append([]byte(nil), bts...)
+	flipped[len(flipped)-40] ^= 1
+	for name, data := range map[string][]byte{
+		"truncated": bts[:len(bts)-10],
+		"flipped":   flipped,
+		"json":      []byte(`{"version":2}`),
+	} {
+		if err := (&NetPerc{}).UnmarshalBinary(data); err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+}
+
+// forgedStream is a binary stream of n with its head changed by edit and
+// nothing after the head.
+func forgedStream(t *testing.T, n *NetPerc, edit func(h *binaryHead)) []byte {
+	t.Helper()
+	head := binaryHead{netFile: *n.file()}
+	head.Weights = nil
+	for _, l := range n.Dense {
+		head.Shapes = append(head.Shapes, binaryShape{In: l.In, Out: l.Out, Bias: l.Bias})
+	}
+	edit(&head)
+	bts, err := json.Marshal(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	buf.Write(binaryMagic)
+	buf.Write([]byte{FormatVersion, 0})
+	binary.Write(&buf, binary.LittleEndian, uint32(len(bts)))
+	buf.Write(bts)
+	return buf.Bytes()
+}
+
+func TestBinaryForgedHead(t *testing.T) {
+	n := InitNetPerc(1, 4).CreateNet(xorData, 1)
+	edits := map[string]func(h *binaryHead){
+		"more layers":   func(h *binaryHead) { h.Shapes = append(h.Shapes, h.Shapes[1]) },
+		"wider layer":   func(h *binaryHead) { h.Shapes[0].Out = 1 << 20 },
+		"not chained":   func(h *binaryHead) { h.Shapes[1].In = 3 },
+		"many slots":    func(h *binaryHead) { h.Shapes[0].SlotsW = 1 << 20 },
+		"negative size": func(h *binaryHead) { h.Arch.Inps, h.Shapes[0].In = -2, -2 },
+		"overflow": func(h *binaryHead) {
+			h.Arch.Inps, h.Arch.Neurons = 1<<40, 1<<40
+			h.Shapes[0].In, h.Shapes[0].Out, h.Shapes[1].In = 1<<40, 1<<40, 1<<40
+		},
+	}
+	for name, edit := range edits {
+		if err := (&NetPerc{}).UnmarshalBinary(forgedStream(t, n, edit)); err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+
+	// a head that fits its architecture may still promise far more weights
+	// than the stream holds, that must not be allocated up front
+	big := forgedStream(t, n, func(h *binaryHead) {
+		h.Arch.Inps, h.Arch.Neurons = 1<<12, 1<<12
+		h.Shapes[0].In, h.Shapes[0].Out, h.Shapes[1].In = 1<<12, 1<<12, 1<<12
+	})
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if err := (&NetPerc{}).UnmarshalBinary(big); err == nil {
+		t.Fatal("a stream without weights was loaded")
+	}
+	runtime.ReadMemStats(&after)
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 16<<20 {
+		t.Fatalf("allocated %d bytes for a stream of %d", alloc, len(big))
+	}
+}
+
+func TestCopy(t *testing.T) {
+	n := trainedNet()
+	n.AddCallback(NopCallback{})
+	c := n.Copy()
+	if !sameFloats(snapshot(c), snapshot(n)) {
+		t.Fatal("copy has other weights")
+	}
+	for _, dt := range n.Data {
+		want, got := n.PredictProba(dt.Inputs), c.PredictProba(dt.Inputs)
+		for k := range want {
+			if got[k] != want[k] {
+				t.Fatalf("copy predicts %v, the net %v", got, want)
+			}
+		}
+	}
+	if c.Error != 1 || len(c.Callbacks) != 0 || c.Optim.Step != n.Optim.Step {
+		t.Fatalf("copy has error %g, %d callbacks and step %d", c.Error, len(c.Callbacks), c.Optim.Step)
+	}
+
+	orig := snapshot(n)
+	slot := n.Dense[0].OptW[0][0]
+	input := n.Data[0].Inputs[0]
+	for _, l := range c.Dense {
+		for k := range l.Weights {
+			l.Weights[k] += 1
+		}
+		for k := range l.Biases {
+			l.Biases[k] += 1
+		}
+		l.OptW[0][0] += 1
+	}
+	c.Data[0].Inputs[0] += 1
+	c.Net[0][0].Weights[0] += 1
+	if !sameFloats(snapshot(n), orig) || n.Dense[0].OptW[0][0] != slot || n.Data[0].Inputs[0] != input {
+		t.Fatal("changing the copy changed the net")
+	}
+	if !c.linked() || c.Dense[0].Weights[0] != orig[0][0]+1+1 {
+		t.Fatal("Net of the copy is not a view of its own layers")
+	}
+}
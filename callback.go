@@ -0,0 +1,184 @@
+package neuro
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// ErrStop can be returned by a callback to end training without it being
+// reported as a failure.
+var ErrStop = errors.New("training stopped by callback")
+
+type EpochMetrics struct {
+	Epoch      int           `json:"epoch"`
+	Iters      int           `json:"iters"`
+	Error      float64       `json:"error"`
+	ValidError float64       `json:"valid_error"`
+	Rate       float64       `json:"rate"`
+	BestValid  float64       `json:"best_valid"`
+	Elapsed    time.Duration `json:"elapsed"`
+	EarlyStop  bool          `json:"early_stop"`
+	Last       bool          `json:"last"`
+}
+
+type BatchMetrics struct {
+	Epoch int     `json:"epoch"`
+	Step  int     `json:"step"`
+	Size  int     `json:"size"`
+	Rate  float64 `json:"rate"`
+}
+
+// GenMetrics describes a generation of Genetic. For the order search of
+// FirstMutate BestOrder is set instead of Best. NewBest tells that
+// OnNewBest follows for the same generation.
+type GenMetrics struct {
+	Iter       int       `json:"iter"`
+	Score      float64   `json:"score"`
+	Error      float64   `json:"error"`
+	Population int       `json:"population"`
+	NewBest    bool      `json:"new_best"`
+	Best       *NetPerc  `json:"-"`
+	BestOrder  *ResOrder `json:"-"`
+}
+
+// Callback receives training progress. A non nil error ends the running
+// loop. Embed NopCallback to implement only some of the hooks.
+type Callback interface {
+	OnEpochStart(n *NetPerc, m EpochMetrics) error
+	OnEpochEnd(n *NetPerc, m EpochMetrics) error
+	OnBatch(n *NetPerc, m BatchMetrics) error
+	OnGeneration(g *Genetic, m GenMetrics) error
+	OnNewBest(g *Genetic, m GenMetrics) error
+}
+
+type NopCallback struct{}
+
+func (NopCallback) OnEpochStart(n *NetPerc, m EpochMetrics) error { return nil }
+func (NopCallback) OnEpochEnd(n *NetPerc, m EpochMetrics) error   { return nil }
+func (NopCallback) OnBatch(n *NetPerc, m BatchMetrics) error      { return nil }
+func (NopCallback) OnGeneration(g *Genetic, m GenMetrics) error   { return nil }
+func (NopCallback) OnNewBest(g *Genetic, m GenMetrics) error      { return nil }
+
+// LogCallback is the default logging to the log package, printing every
+// Every epoch or generation.
+type LogCallback struct {
+	NopCallback
+	Every int
+}
+
+func (c LogCallback) every() int {
+	if c.Every < 1 {
+		return 50
+	}
+	return c.Every
+}
+
+func (c LogCallback) OnEpochEnd(n *NetPerc, m EpochMetrics) error {
+	n.logIter(m.Epoch, c.every())
+	if m.EarlyStop {
+		log.Println("early stop at iteration: ", m.Epoch, "; best valid error: ", m.BestValid)
+	}
+	if m.Last {
+		log.Println("teach time:", m.Elapsed.String())
+	}
+	return nil
+}
+
+// OnGeneration leaves generations with a new best to OnNewBest, so each
+// generation is logged once.
+func (c LogCallback) OnGeneration(g *Genetic, m GenMetrics) error {
+	if m.NewBest {
+		return nil
+	}
+	if m.BestOrder != nil {
+		g.LogScoreOrders(c.every(), " !!! TIME !!! ")
+	} else {
+		g.LogScore(c.every())
+	}
+	return nil
+}
+
+func (c LogCallback) OnNewBest(g *Genetic, m GenMetrics) error {
+	if m.BestOrder != nil {
+		g.LogScoreOrders(1, " !!! BEST !!! ")
+	} else {
+		g.LogScore(1)
+	}
+	return nil
+}
+
+func (n *NetPerc) AddCallback(cb Callback) *NetPerc {
+	n.Callbacks = append(n.Callbacks, cb)
+	return n
+}
+
+func (g *Genetic) AddCallback(cb Callback) *Genetic {
+	g.Callbacks = append(g.Callbacks, cb)
+	return g
+}
+
+func (n *NetPerc) emitEpoch(start bool, m EpochMetrics) error {
+	for _, cb := range n.callbacks {
+		var err error
+		if start {
+			err = cb.OnEpochStart(n, m)
+		} else {
+			err = cb.OnEpochEnd(n, m)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *NetPerc) emitBatch(m BatchMetrics) error {
+	for _, cb := range n.callbacks {
+		if err := cb.OnBatch(n, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emitGeneration reports a finished generation and, when newBest is set,
+// the new best solution. def is used when no callbacks were added. The
+// first error is kept in g.Err.
+func (g *Genetic) emitGeneration(m GenMetrics, newBest bool, def Callback) error {
+	cbs := g.Callbacks
+	if len(cbs) == 0 && def != nil {
+		cbs = []Callback{def}
+	}
+	m.NewBest = newBest
+	for _, cb := range cbs {
+		err := cb.OnGeneration(g, m)
+		if err == nil && newBest {
+			err = cb.OnNewBest(g, m)
+		}
+		if err != nil {
+			g.Err = err
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Genetic) genMetrics() GenMetrics {
+	m := GenMetrics{Iter: g.Iters, Population: len(g.Nets)}
+	if len(g.Nets) > 0 {
+		m.Best = g.GetBest()
+		m.Score = m.Best.Score
+		m.Error = m.Best.Error
+	}
+	return m
+}
+
+func (g *Genetic) orderMetrics() GenMetrics {
+	m := GenMetrics{Iter: g.Iters, Population: len(g.ResOrders)}
+	if len(g.ResOrders) > 0 {
+		m.BestOrder = g.GetBestOrders()
+		m.Score = m.BestOrder.Score
+	}
+	return m
+}
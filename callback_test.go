@@ -0,0 +1,47 @@
+package neuro
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+type stopAt struct {
+	NopCallback
+	epoch int
+	err   error
+}
+
+func (c stopAt) OnEpochEnd(n *NetPerc, m EpochMetrics) error {
+	if m.Epoch == c.epoch {
+		return c.err
+	}
+	return nil
+}
+
+func TestCallbackStop(t *testing.T) {
+	n := InitNetPerc(1, 4).CreateNet(xorData, 10).AddCallback(stopAt{epoch: 2, err: ErrStop})
+	n.Train()
+	if n.Epoch != 3 {
+		t.Fatalf("trained %d epochs, want a stop after 3", n.Epoch)
+	}
+}
+
+func TestLogCallbackOnce(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	g := &Genetic{Nets: []*NetPerc{InitNetPerc(1, 4).CreateNet(xorData, 1)}}
+	for _, newBest := range []bool{false, true} {
+		buf.Reset()
+		if err := g.emitGeneration(g.genMetrics(), newBest, LogCallback{Every: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if lines := strings.Count(buf.String(), "\n"); lines != 1 {
+			t.Errorf("new best %v: logged %d lines, want 1:\n%s", newBest, lines, buf.String())
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package neuro
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"sync"
+)
+
+// CMA trains the weights and biases of a net with the separable CMA-ES of
+// Ros and Hansen: a normal distribution with a diagonal covariance is moved
+// towards the fittest of Lambda sampled nets every generation. It keeps one
+// variance per weight, so it scales to large nets where random single
+// weight mutation does not.
+type CMA struct {
+	Nets    []*NetPerc `json:"-"`
+	Config  CMAConf    `json:"conf"`
+	Mean    []float64  `json:"mean"`
+	Diag    []float64  `json:"diag"`
+	Ps      []float64  `json:"ps"`
+	Pc      []float64  `json:"pc"`
+	Sigma   float64    `json:"sigma"`
+	Iters   int        `json:"iters"`
+	Score   float64    `json:"score"`
+	Error   float64    `json:"error"`
+	Fitness Fitness    `json:"-"`
+	Err     error      `json:"-"`
+	base    *NetPerc
+	best    *NetPerc
+	hasBest bool
+	zs      [][]float64
+	src     *splitMix
+	rng     *rand.Rand
+}
+
+// CMAConf sets up a CMA run. Lambda defaults to 4+3ln(weights) and Sigma,
+// the initial step, to 0.5. Budget, Hours and TradesByDay are used like in
+// GeneticConf.
+type CMAConf struct {
+	Lambda          int     `json:"lambda"`
+	Sigma           float64 `json:"sigma"`
+	Budget          float64 `json:"budget"`
+	Hours           float64 `json:"hours"`
+	TradesByDay     float64 `json:"trades_by_day"`
+	Seed            int64   `json:"seed"`
+	CheckpointEvery int     `json:"checkpoint_every"`
+	CheckpointFile  string  `json:"checkpoint_file"`
+}
+
+// InitCMA starts the search at the weights of net, whose data and layout
+// all sampled nets share.
+func InitCMA(net *NetPerc, conf ...CMAConf) *CMA {
+	c := &CMA{Error: 1}
+	if len(conf) != 0 {
+		c.Config = conf[0]
+	}
+	c.base = net
+	c.Mean = flatten(net)
+	size := len(c.Mean)
+	if c.Config.Lambda < 2 {
+		c.Config.Lambda = 4 + int(3*math.Log(float64(size)))
+	}
+	c.Sigma = orDefault(c.Config.Sigma, 0.5)
+	c.Diag = make([]float64, size)
+	for i := range c.Diag {
+		c.Diag[i] = 1
+	}
+	c.Ps = make([]float64, size)
+	c.Pc = make([]float64, size)
+	return c
+}
+
+// SetFitness sets what the sampled nets are ranked by, TradeFitness with
+// the values of the config by default. It is not saved.
+func (c *CMA) SetFitness(f Fitness) *CMA {
+	c.Fitness = f
+	return c
+}
+
+func (c *CMA) fitness() Fitness {
+	if c.Fitness != nil {
+		return c.Fitness
+	}
+	return TradeFitness(c.Config.Hours, c.Config.TradesByDay)
+}
+
+// GetBest returns the fittest net sampled so far, the starting net before
+// the first generation.
+func (c *CMA) GetBest() *NetPerc {
+	if c.best == nil {
+		return c.base
+	}
+	return c.best
+}
+
+// GetMean returns a net with the mean of the distribution as weights, which
+// is often better than any single sample late in a run.
+func (c *CMA) GetMean() *NetPerc {
+	n := shell(c.base)
+	unflatten(n, c.Mean)
+	return n
+}
+
+// Train scores every sampled net on a random sample of its data, like
+// Genetic.Train, and moves the distribution unless last is set.
+func (c *CMA) Train(last bool) {
+	c.samples()
+	rngs := c.forks(len(c.Nets))
+	var wg sync.WaitGroup
+	wg.Add(len(c.Nets))
+	for i := range c.Nets {
+		go func(ind int) {
+			defer wg.Done()
+			c.Nets[ind].SetRand(rngs[ind]).TrainIter()
+		}(i)
+	}
+	wg.Wait()
+	c.generation(!last)
+}
+
+// TrainItem runs ret on every sampled net in its own goroutine, after the
+// trading state was reset. Iterate then ranks them.
+func (c *CMA) TrainItem(ret func(n *NetPerc)) {
+	c.samples()
+	rngs := c.forks(len(c.Nets))
+	var wg sync.WaitGroup
+	wg.Add(len(c.Nets))
+	for i := range c.Nets {
+		go func(ind int) {
+			defer wg.Done()
+			n := c.Nets[ind].SetRand(rngs[ind])
+			n.Trades = 0
+			n.Nols = 0
+			n.Score = 0
+			n.Budget = c.Config.Budget
+			n.LastPrice = 0
+			n.DiffPerce = 0
+			n.StatusBSell = false
+			n.Returns = n.Returns[:0]
+			ret(n)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Iterate moves the distribution after TrainItem. It reports true when the
+// run should stop: a checkpoint failed or the step has collapsed.
+func (c *CMA) Iterate() bool {
+	c.samples()
+	c.generation(true)
+	if c.Err != nil {
+		return true
+	}
+	var dev float64
+	for _, d := range c.Diag {
+		dev = math.Max(dev, d)
+	}
+	return c.Sigma*math.Sqrt(dev) < 1e-12
+}
+
+func (c *CMA) generation(update bool) {
+	fit := c.fitness()
+	var wg sync.WaitGroup
+	wg.Add(len(c.Nets))
+	for _, n := range c.Nets {
+		go func(n *NetPerc) {
+			defer wg.Done()
+			n.Score = fit(n)
+		}(n)
+	}
+	wg.Wait()
+	scores := make([]float64, len(c.Nets))
+	for i, n := range c.Nets {
+		scores[i] = n.Score
+	}
+	order := ranking(scores)
+	if top := c.Nets[order[0]]; !c.hasBest || fitter(top.Score, c.Score) {
+		c.best = shell(top)
+		c.best.Error = top.Error
+		c.best.Score = top.Score
+		c.Score = top.Score
+		c.Error = top.Error
+		c.hasBest = true
+	}
+	if !update {
+		return
+	}
+	c.update(order)
+	c.Iters += 1
+	c.checkpoint()
+	c.Nets = nil
+}
+
+// update is one step of sep-CMA-ES with the nets ranked by order.
+func (c *CMA) update(order []int) {
+	size := float64(len(c.Mean))
+	mu := len(order) / 2
+	weights := make([]float64, mu)
+	var sum, sq float64
+	for i := range weights {
+		weights[i] = math.Log(float64(mu)+0.5) - math.Log(float64(i+1))
+		sum += weights[i]
+	}
+	for i := range weights {
+		weights[i] /= sum
+		sq += weights[i] * weights[i]
+	}
+	mueff := 1 / sq
+	cs := (mueff + 2) / (size + mueff + 5)
+	cc := (4 + mueff/size) / (size + 4 + 2*mueff/size)
+	c1 := 2 / ((size+1.3)*(size+1.3) + mueff)
+	cmu := math.Min(1-c1, 2*(mueff-2+1/mueff)/((size+2)*(size+2)+mueff))
+	// the diagonal model learns faster, by (n+2)/3
+	c1 = math.Min(1, c1*(size+2)/3)
+	cmu = math.Min(1-c1, cmu*(size+2)/3)
+	damps := 1 + 2*math.Max(0, math.Sqrt((mueff-1)/(size+1))-1) + cs
+	chiN := math.Sqrt(size) * (1 - 1/(4*size) + 1/(21*size*size))
+
+	zw := make([]float64, len(c.Mean))
+	for i, w := range weights {
+		for j, z := range c.zs[order[i]] {
+			zw[j] += w * z
+		}
+	}
+	var psNorm float64
+	for j := range c.Ps {
+		c.Ps[j] = (1-cs)*c.Ps[j] + math.Sqrt(cs*(2-cs)*mueff)*zw[j]
+		psNorm += c.Ps[j] * c.Ps[j]
+	}
+	psNorm = math.Sqrt(psNorm)
+	hsig := 0.0
+	if psNorm/math.Sqrt(1-math.Pow(1-cs, float64(2*(c.Iters+1))))/chiN < 1.4+2/(size+1) {
+		hsig = 1
+	}
+	for j := range c.Mean {
+		dev := math.Sqrt(c.Diag[j])
+		yw := dev * zw[j]
+		c.Mean[j] += c.Sigma * yw
+		c.Pc[j] = (1-cc)*c.Pc[j] + hsig*math.Sqrt(cc*(2-cc)*mueff)*yw
+		var rankMu float64
+		for i, w := range weights {
+			y := dev * c.zs[order[i]][j]
+			rankMu += w * y * y
+		}
+		c.Diag[j] = (1-c1-cmu)*c.Diag[j] +
+			c1*(c.Pc[j]*c.Pc[j]+(1-hsig)*cc*(2-cc)*c.Diag[j]) +
+			cmu*rankMu
+	}
+	c.Sigma *= math.Exp(math.Min(1, cs/damps*(psNorm/chiN-1)))
+}
+
+// samples draws Lambda nets from the distribution unless the current
+// generation has them already.
+func (c *CMA) samples() {
+	if c.Nets != nil {
+		return
+	}
+	lambda := c.Config.Lambda
+	if len(c.zs) != lambda {
+		c.zs = make([][]float64, lambda)
+	}
+	c.Nets = make([]*NetPerc, lambda)
+	for k := range c.Nets {
+		c.Nets[k] = shell(c.base)
+	}
+	rngs := c.forks(lambda)
+	var wg sync.WaitGroup
+	wg.Add(lambda)
+	for k := 0; k < lambda; k++ {
+		go func(k int) {
+			defer wg.Done()
+			z := make([]float64, len(c.Mean))
+			x := make([]float64, len(c.Mean))
+			for j := range z {
+				z[j] = rngs[k].NormFloat64()
+				x[j] = c.Mean[j] + c.Sigma*math.Sqrt(c.Diag[j])*z[j]
+			}
+			unflatten(c.Nets[k], x)
+			c.zs[k] = z
+		}(k)
+	}
+	wg.Wait()
+}
+
+// shell copies n like Copy, but the copy shares the data of n.
+func shell(n *NetPerc) *NetPerc {
+	data := n.Data
+	n.Data = nil
+	nn := n.Copy()
+	n.Data = data
+	nn.Data = data
+	return nn
+}
+
+// flatten lists the weights of n, then its biases if it has them, layer by
+// layer. It is the order of genes.
+func flatten(n *NetPerc) []float64 {
+	n.prepare()
+	fls := make([]float64, 0, genes(n))
+	for _, l := range n.Dense {
+		fls = append(fls, l.Weights...)
+		if l.Bias {
+			fls = append(fls, l.Biases...)
+		}
+	}
+	return fls
+}
+
+func unflatten(n *NetPerc, fls []float64) {
+	n.prepare()
+	for _, l := range n.Dense {
+		fls = fls[copy(l.Weights, fls):]
+		if l.Bias {
+			fls = fls[copy(l.Biases, fls):]
+		}
+	}
+}
+
+func (c *CMA) Save(fileName string) error {
+	if fileName == "" {
+		return errors.New("empty filename")
+	}
+	return writeFile(fileName, c.Encode)
+}
+
+func (c *CMA) Encode(w io.Writer) error {
+	bts, err := c.encode()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(bts)
+	return err
+}
+
+// Decode reads a run written by Encode or Save into c. The data and the
+// fitness of c are kept.
+func (c *CMA) Decode(r io.Reader) error {
+	bts, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	f, base, best, err := decodeCMA(bts)
+	if err != nil {
+		return err
+	}
+	if c.base != nil {
+		base.Data = c.base.Data
+	}
+	if best != nil {
+		best.Data = base.Data
+	}
+	c.Nets = nil
+	c.zs = nil
+	c.Config = f.Config
+	c.Mean = f.Mean
+	c.Diag = f.Diag
+	c.Ps = f.Ps
+	c.Pc = f.Pc
+	c.Sigma = f.Sigma
+	c.Iters = f.Iters
+	c.Score = float64(f.Score)
+	c.Error = float64(f.Error)
+	c.base = base
+	c.best = best
+	c.hasBest = f.HasBest
+	c.src, c.rng = nil, nil
+	if f.Rand != nil {
+		c.src = &splitMix{state: *f.Rand}
+		c.rng = rand.New(c.src)
+	}
+	return nil
+}
+
+// Resume loads a checkpoint into c and gives the nets data, which is not
+// part of the file.
+func (c *CMA) Resume(fileName string, data []DataTeach) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := c.Decode(f); err != nil {
+		return fmt.Errorf("resume %s: %v", fileName, err)
+	}
+	if data != nil {
+		c.base.Data = data
+		if c.best != nil {
+			c.best.Data = data
+		}
+	}
+	return nil
+}
+
+// checkpoint saves the run every Config.CheckpointEvery generations, a
+// failed save is kept in c.Err.
+func (c *CMA) checkpoint() error {
+	conf := c.Config
+	if conf.CheckpointEvery < 1 || conf.CheckpointFile == "" || c.Iters%conf.CheckpointEvery != 0 {
+		return nil
+	}
+	if err := c.Save(conf.CheckpointFile); err != nil {
+		c.Err = err
+		return err
+	}
+	return nil
+}
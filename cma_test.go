@@ -0,0 +1,75 @@
+package neuro
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func testCMA(seed int64, conf CMAConf, gens int) *CMA {
+	net := InitNetPerc(2, 4).SetBias(true).SetSeed(1).SetWeight(-1, 1).CreateNet(xorData, 1)
+	c := InitCMA(net, conf).SetSeed(seed).SetFitness(ErrorFitness)
+	runCMA(c, gens)
+	return c
+}
+
+func runCMA(c *CMA, gens int) {
+	for i := 0; i < gens; i++ {
+		c.TrainItem(func(n *NetPerc) { n.Error = n.calcDataSetError(n.Data) })
+		if c.Iterate() {
+			return
+		}
+	}
+}
+
+func sameCMA(t *testing.T, what string, a, b *CMA) {
+	t.Helper()
+	if a.Iters != b.Iters || a.Sigma != b.Sigma || a.Error != b.Error || a.Score != b.Score {
+		t.Fatalf("%s: generation %d sigma %g error %g, and %d %g %g", what,
+			a.Iters, a.Sigma, a.Error, b.Iters, b.Sigma, b.Error)
+	}
+	if !sameFloats([][]float64{a.Mean, a.Diag}, [][]float64{b.Mean, b.Diag}) {
+		t.Fatalf("%s: the distributions differ", what)
+	}
+}
+
+func TestCMASeed(t *testing.T) {
+	a := testCMA(7, CMAConf{}, 20)
+	b := testCMA(7, CMAConf{}, 20)
+	sameCMA(t, "same seed", a, b)
+	if c := testCMA(8, CMAConf{}, 20); sameFloats([][]float64{a.Mean}, [][]float64{c.Mean}) {
+		t.Fatal("another seed gave the same mean")
+	}
+	if start := testCMA(7, CMAConf{}, 0).GetBest().calcDataSetError(xorData); a.Error >= start {
+		t.Fatalf("error %g, started at %g", a.Error, start)
+	}
+}
+
+func TestCMAResume(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cma.json")
+	straight := testCMA(7, CMAConf{}, 30)
+
+	testCMA(7, CMAConf{CheckpointEvery: 10, CheckpointFile: file}, 20)
+	resumed := &CMA{}
+	if err := resumed.Resume(file, xorData); err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Iters != 20 {
+		t.Fatalf("resumed at generation %d, want 20", resumed.Iters)
+	}
+	resumed.SetFitness(ErrorFitness)
+	runCMA(resumed, 10)
+	sameCMA(t, "resumed", straight, resumed)
+}
+
+func TestCMADecodeCorrupt(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testCMA(7, CMAConf{}, 5).Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	bts := buf.Bytes()
+	bts[len(bts)/2] ^= 1
+	if err := (&CMA{}).Decode(bytes.NewReader(bts)); err == nil {
+		t.Fatal("a corrupt file was accepted")
+	}
+}
@@ -0,0 +1,215 @@
+package neuro
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Crossover builds a child from two parents of the same shape. The child
+// starts as a copy of a, the parents are not changed. Parents with
+// different layers cannot be combined and give a plain copy of a.
+type Crossover interface {
+	Name() string
+	Cross(rng *rand.Rand, a, b *NetPerc) *NetPerc
+}
+
+// UniformCrossover takes every weight from either parent with equal
+// probability.
+type UniformCrossover struct{}
+
+func (UniformCrossover) Name() string { return "uniform" }
+
+func (UniformCrossover) Cross(rng *rand.Rand, a, b *NetPerc) *NetPerc {
+	return crossGenes(a, b, func(dst, src []float64) {
+		for i, v := range src {
+			if rng.Intn(2) == 1 {
+				dst[i] = v
+			}
+		}
+	})
+}
+
+// PointCrossover cuts the weights and the biases of every layer at Points
+// random places, one by default, and takes the pieces from the parents in
+// turn.
+type PointCrossover struct {
+	Points int
+}
+
+func (c PointCrossover) Name() string { return "point:" + strconv.Itoa(c.Points) }
+
+func (c PointCrossover) Cross(rng *rand.Rand, a, b *NetPerc) *NetPerc {
+	points := c.Points
+	if points < 1 {
+		points = 1
+	}
+	return crossGenes(a, b, func(dst, src []float64) {
+		if len(dst) < 2 {
+			return
+		}
+		cuts := make([]bool, len(dst))
+		for p := 0; p < points; p++ {
+			cuts[1+rng.Intn(len(dst)-1)] = true
+		}
+		fromB := false
+		for i, v := range src {
+			if cuts[i] {
+				fromB = !fromB
+			}
+			if fromB {
+				dst[i] = v
+			}
+		}
+	})
+}
+
+// BlendCrossover is BLX-α: every weight is drawn from the range spanned by
+// both parents, widened by Alpha times its length on each side. Alpha
+// defaults to 0.5.
+type BlendCrossover struct {
+	Alpha float64
+}
+
+func (c BlendCrossover) Name() string { return "blx:" + strconv.FormatFloat(c.Alpha, 'g', -1, 64) }
+
+func (c BlendCrossover) Cross(rng *rand.Rand, a, b *NetPerc) *NetPerc {
+	alpha := orDefault(c.Alpha, 0.5)
+	return crossGenes(a, b, func(dst, src []float64) {
+		for i, v := range src {
+			lo, hi := dst[i], v
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			d := alpha * (hi - lo)
+			dst[i] = randFloat(rng, lo-d, hi+d)
+		}
+	})
+}
+
+// NeuronCrossover takes every neuron, its incoming weights together with
+// its bias, from either parent, so neurons are never split.
+type NeuronCrossover struct{}
+
+func (NeuronCrossover) Name() string { return "neuron" }
+
+func (NeuronCrossover) Cross(rng *rand.Rand, a, b *NetPerc) *NetPerc {
+	child := a.Copy()
+	if !sameShape(a, b) {
+		return child
+	}
+	for il, l := range child.Dense {
+		src := b.Dense[il]
+		for j := 0; j < l.Out; j++ {
+			if rng.Intn(2) == 0 {
+				continue
+			}
+			for i := 0; i < l.In; i++ {
+				l.Weights[i*l.Out+j] = src.Weights[i*l.Out+j]
+			}
+			l.Biases[j] = src.Biases[j]
+		}
+	}
+	return child
+}
+
+// crossGenes copies a and lets mix combine the weights, then the biases of
+// every layer of the copy with those of b.
+func crossGenes(a, b *NetPerc, mix func(dst, src []float64)) *NetPerc {
+	child := a.Copy()
+	if !sameShape(a, b) {
+		return child
+	}
+	for il, l := range child.Dense {
+		mix(l.Weights, b.Dense[il].Weights)
+		if l.Bias {
+			mix(l.Biases, b.Dense[il].Biases)
+		}
+	}
+	return child
+}
+
+func sameShape(a, b *NetPerc) bool {
+	if len(a.Dense) == 0 || len(a.Dense) != len(b.Dense) {
+		return false
+	}
+	for il, l := range a.Dense {
+		o := b.Dense[il]
+		if l.In != o.In || l.Out != o.Out || l.Bias != o.Bias {
+			return false
+		}
+	}
+	return true
+}
+
+func CrossoverByName(name string) (Crossover, error) {
+	base, param := name, ""
+	if i := strings.Index(name, ":"); i >= 0 {
+		base, param = name[:i], name[i+1:]
+	}
+	switch base {
+	case "", "uniform":
+		return UniformCrossover{}, nil
+	case "point":
+		c := PointCrossover{Points: 1}
+		if param != "" {
+			points, err := strconv.Atoi(param)
+			if err != nil {
+				return nil, err
+			}
+			c.Points = points
+		}
+		return c, nil
+	case "blx":
+		c := BlendCrossover{Alpha: 0.5}
+		if param != "" {
+			alpha, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				return nil, err
+			}
+			c.Alpha = alpha
+		}
+		return c, nil
+	case "neuron":
+		return NeuronCrossover{}, nil
+	}
+	return nil, fmt.Errorf("unknown crossover %q", name)
+}
+
+// SetCrossover picks the operator used for Config.CrossoverRate of the
+// children, uniform crossover without one.
+func (g *Genetic) SetCrossover(c Crossover) *Genetic {
+	g.Config.Crossover = ""
+	if c != nil {
+		g.Config.Crossover = c.Name()
+	}
+	g.cross = c
+	return g
+}
+
+func (g *Genetic) crossover() Crossover {
+	if g.cross == nil {
+		g.cross, _ = CrossoverByName(g.Config.Crossover)
+		if g.cross == nil {
+			g.cross = UniformCrossover{}
+		}
+	}
+	return g.cross
+}
+
+// breed makes a child of g.Nets[parent]. With Config.CrossoverRate it is
+// crossed with g.Nets[mate] first, or with a random other survivor when
+// both are the same net.
+func (g *Genetic) breed(rng *rand.Rand, cross Crossover, parent, mate int) *NetPerc {
+	if g.Config.CrossoverRate <= 0 || len(g.Nets) < 2 || rng.Float64() >= g.Config.CrossoverRate {
+		return g.Nets[parent].Copy()
+	}
+	if mate == parent {
+		mate = rng.Intn(len(g.Nets) - 1)
+		if mate >= parent {
+			mate++
+		}
+	}
+	return cross.Cross(rng, g.Nets[parent], g.Nets[mate])
+}
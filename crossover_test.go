@@ -0,0 +1,109 @@
+package neuro
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func testParents() (*NetPerc, *NetPerc) {
+	mk := func(seed int64) *NetPerc {
+		return InitNetPerc(2, 5).SetBias(true).SetSeed(seed).SetWeight(-1, 1).CreateNet(xorData, 1)
+	}
+	return mk(1), mk(2)
+}
+
+func TestCrossover(t *testing.T) {
+	cases := []struct {
+		cross Crossover
+		// blend draws new values between the parents instead of taking
+		// them from either one
+		blend bool
+	}{
+		{UniformCrossover{}, false},
+		{PointCrossover{Points: 1}, false},
+		{PointCrossover{Points: 3}, false},
+		{BlendCrossover{Alpha: 0.5}, true},
+		{NeuronCrossover{}, false},
+	}
+	rng := rand.New(rand.NewSource(1))
+	for _, c := range cases {
+		name := c.cross.Name()
+		a, b := testParents()
+		sa, sb := snapshot(a), snapshot(b)
+		child := c.cross.Cross(rng, a, b)
+		if !sameFloats(snapshot(a), sa) || !sameFloats(snapshot(b), sb) {
+			t.Errorf("%s: changed a parent", name)
+		}
+		if !sameShape(child, a) {
+			t.Errorf("%s: child has another shape", name)
+			continue
+		}
+		fromA, fromB := 0, 0
+		for il, l := range child.Dense {
+			for k, w := range l.Weights {
+				x, y := a.Dense[il].Weights[k], b.Dense[il].Weights[k]
+				switch {
+				case w == x:
+					fromA++
+				case w == y:
+					fromB++
+				case !c.blend:
+					t.Errorf("%s: layer %d weight %d comes from neither parent", name, il, k)
+				default:
+					lo, hi := x, y
+					if lo > hi {
+						lo, hi = hi, lo
+					}
+					if d := 0.5 * (hi - lo); w < lo-d || w > hi+d {
+						t.Errorf("%s: layer %d weight %d outside the blend range", name, il, k)
+					}
+				}
+			}
+		}
+		if !c.blend && (fromA == 0 || fromB == 0) {
+			t.Errorf("%s: %d genes from a, %d from b", name, fromA, fromB)
+		}
+		byName, err := CrossoverByName(name)
+		if err != nil || byName != c.cross {
+			t.Errorf("%s: by name gives %v, %v", name, byName, err)
+		}
+	}
+}
+
+func TestNeuronCrossoverKeepsNeurons(t *testing.T) {
+	a, b := testParents()
+	child := NeuronCrossover{}.Cross(rand.New(rand.NewSource(1)), a, b)
+	for il, l := range child.Dense {
+		for j := 0; j < l.Out; j++ {
+			from := a.Dense[il]
+			if l.Biases[j] != from.Biases[j] {
+				from = b.Dense[il]
+			}
+			for i := 0; i < l.In; i++ {
+				if k := i*l.Out + j; l.Weights[k] != from.Weights[k] {
+					t.Errorf("layer %d: neuron %d mixes both parents", il, j)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestCrossoverShapeMismatch(t *testing.T) {
+	a, _ := testParents()
+	other := InitNetPerc(1, 3).SetBias(true).CreateNet(xorData, 1)
+	for _, cross := range []Crossover{UniformCrossover{}, PointCrossover{}, BlendCrossover{}, NeuronCrossover{}} {
+		child := cross.Cross(rand.New(rand.NewSource(1)), a, other)
+		if !sameFloats(snapshot(child), snapshot(a)) {
+			t.Errorf("%s: mismatched parents did not give a copy of a", cross.Name())
+		}
+	}
+}
+
+func TestCrossoverByNameErrors(t *testing.T) {
+	for _, name := range []string{"cut", "point:x", "blx:y"} {
+		if _, err := CrossoverByName(name); err == nil {
+			t.Errorf("%q accepted", name)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package neuro
+
+import (
+	"fmt"
+
+	"github.com/alexber1277/neuro/metrics"
+)
+
+// Evaluate scores the net on data with the metrics package. n.Result is
+// filled from the report, so GetStat keeps giving a short summary.
+func (n *NetPerc) Evaluate(data []*DataTeach) (metrics.Classification, error) {
+	outputs, targets, err := n.predictData(data)
+	if err != nil {
+		return metrics.Classification{}, err
+	}
+	report, err := metrics.Classify(outputs, targets)
+	if err != nil {
+		return report, err
+	}
+	n.Result = Result{
+		Percent: report.Accuracy * 100,
+		True:    report.Correct,
+		False:   report.Total - report.Correct,
+	}
+	return report, nil
+}
+
+// EvaluateRegress scores a regression net on data, predictions within tol
+// of the target are hits. n.Result gets the hit rate.
+func (n *NetPerc) EvaluateRegress(data []*DataTeach, tol float64) (metrics.Regression, error) {
+	outputs, targets, err := n.predictData(data)
+	if err != nil {
+		return metrics.Regression{Tolerance: tol}, err
+	}
+	report, err := metrics.Regress(outputs, targets, tol)
+	if err != nil {
+		return report, err
+	}
+	n.Result = Result{
+		Percent: report.HitRate * 100,
+		True:    report.Hits,
+		False:   report.Total - report.Hits,
+	}
+	return report, nil
+}
+
+// predictData predicts the inputs of data, a row the net cannot take is
+// an error instead of a panic in PredictBatch.
+func (n *NetPerc) predictData(data []*DataTeach) (outputs, targets [][]float64, err error) {
+	m := n.Compile()
+	inputs := make([][]float64, len(data))
+	targets = make([][]float64, len(data))
+	for i, dt := range data {
+		if len(dt.Inputs) != m.Inputs() {
+			return nil, nil, fmt.Errorf("row %d: %d inputs, net takes %d", i, len(dt.Inputs), m.Inputs())
+		}
+		inputs[i] = dt.Inputs
+		targets[i] = dt.Outputs
+	}
+	return m.PredictBatch(inputs), targets, nil
+}
@@ -0,0 +1,52 @@
+package neuro
+
+import "testing"
+
+func TestEvaluate(t *testing.T) {
+	n := InitNetPerc(1, 4).SetWeight(-1, 1).CreateNet(xorData, 1)
+	data := make([]*DataTeach, len(xorData))
+	for i := range xorData {
+		data[i] = &xorData[i]
+	}
+	report, err := n.Evaluate(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 4 || n.Result.True != report.Correct || n.Result.False != 4-report.Correct {
+		t.Fatalf("result %+v for %d of %d correct", n.Result, report.Correct, report.Total)
+	}
+
+	bad := map[string]*DataTeach{
+		"short inputs":  {Inputs: []float64{1}, Outputs: []float64{1}},
+		"wide inputs":   {Inputs: []float64{1, 0, 1}, Outputs: []float64{1}},
+		"wide outputs":  {Inputs: []float64{1, 0}, Outputs: []float64{1, 0}},
+		"empty outputs": {Inputs: []float64{1, 0}},
+	}
+	for name, dt := range bad {
+		if _, err := n.Evaluate(append(data[:3:3], dt)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+	if _, err := n.Evaluate(nil); err == nil {
+		t.Error("no error without data")
+	}
+}
+
+func TestEvaluateRegress(t *testing.T) {
+	n := InitNetPerc(1, 4).SetWeight(-1, 1).CreateNet(xorData, 1)
+	data := []*DataTeach{
+		{Inputs: []float64{0, 1}, Outputs: []float64{0.5}},
+		{Inputs: []float64{1, 0}, Outputs: []float64{0.5}},
+	}
+	report, err := n.EvaluateRegress(data, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 2 || report.Hits != 2 || n.Result.True != 2 {
+		t.Fatalf("%d hits of %d, result %+v", report.Hits, report.Total, n.Result)
+	}
+	data = append(data, &DataTeach{Inputs: []float64{1, 1}, Outputs: []float64{0, 1}})
+	if _, err := n.EvaluateRegress(data, 1); err == nil {
+		t.Fatal("no error for a row with two targets")
+	}
+}
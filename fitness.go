@@ -0,0 +1,78 @@
+package neuro
+
+import (
+	"math"
+	"sync"
+)
+
+// Fitness scores a net after a generation, higher is better. Genetic sorts
+// and selects on it, the score is kept in NetPerc.Score.
+type Fitness func(n *NetPerc) float64
+
+// ErrorFitness prefers the nets with the lowest training error.
+func ErrorFitness(n *NetPerc) float64 {
+	return -n.Error
+}
+
+// ProfitFitness is the sum of the price differences of all closed trades.
+func ProfitFitness(n *NetPerc) float64 {
+	return n.DiffPerce
+}
+
+// SharpeFitness is the mean relative return of the closed trades divided by
+// their standard deviation, zero with fewer than two trades.
+func SharpeFitness(n *NetPerc) float64 {
+	if len(n.Returns) < 2 {
+		return 0
+	}
+	var mean, dev float64
+	for _, r := range n.Returns {
+		mean += r
+	}
+	mean /= float64(len(n.Returns))
+	for _, r := range n.Returns {
+		dev += (r - mean) * (r - mean)
+	}
+	dev = math.Sqrt(dev / float64(len(n.Returns)-1))
+	if dev == 0 {
+		return 0
+	}
+	return mean / dev
+}
+
+// TradeFitness is the profit per trade, where hours/24*tradesByDay trades
+// are added to keep nets from trading too little. It is the default of
+// Genetic, with the values of its config.
+func TradeFitness(hours, tradesByDay float64) Fitness {
+	return func(n *NetPerc) float64 {
+		return n.DiffPerce / (hours/24*tradesByDay + float64(n.Trades))
+	}
+}
+
+// SetFitness sets what the nets are sorted by. Like callbacks it is not
+// saved and has to be set again after Load.
+func (g *Genetic) SetFitness(f Fitness) *Genetic {
+	g.Fitness = f
+	return g
+}
+
+func (g *Genetic) fitness() Fitness {
+	if g.Fitness != nil {
+		return g.Fitness
+	}
+	return TradeFitness(g.Config.Hours, g.Config.TradesByDay)
+}
+
+// evaluate scores every net once, each in its own goroutine.
+func (g *Genetic) evaluate() {
+	fit := g.fitness()
+	var wg sync.WaitGroup
+	wg.Add(len(g.Nets))
+	for _, n := range g.Nets {
+		go func(n *NetPerc) {
+			defer wg.Done()
+			n.Score = fit(n)
+		}(n)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,73 @@
+package neuro
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFitness(t *testing.T) {
+	// buy at 100, sell at 110, buy at 100, sell at 105
+	trades := &NetPerc{}
+	for _, s := range []struct {
+		rsp   []float64
+		price float64
+	}{
+		{[]float64{0, 1, 0}, 100},
+		{[]float64{0, 0, 1}, 110},
+		{[]float64{0, 1, 0}, 100},
+		{[]float64{0, 0, 1}, 105},
+	} {
+		trades.Operate(s.rsp, DataTeach{Price: s.price})
+	}
+	trades.Error = 0.25
+	mean := (0.1 + 0.05) / 2
+	dev := math.Sqrt(2 * 0.025 * 0.025)
+	cases := []struct {
+		name string
+		fit  Fitness
+		n    *NetPerc
+		want float64
+	}{
+		{"error", ErrorFitness, trades, -0.25},
+		{"profit", ProfitFitness, trades, 15},
+		{"sharpe", SharpeFitness, trades, mean / dev},
+		{"sharpe one trade", SharpeFitness, &NetPerc{Returns: []float64{0.5}}, 0},
+		{"sharpe flat", SharpeFitness, &NetPerc{Returns: []float64{0, 0, 0}}, 0},
+		{"sharpe gains", SharpeFitness, &NetPerc{Returns: []float64{1, 2, 3}}, 2},
+		{"trade", TradeFitness(24, 2), trades, 15.0 / 4},
+		{"trade idle", TradeFitness(48, 1), &NetPerc{}, 0},
+	}
+	for _, c := range cases {
+		if got := c.fit(c.n); math.Abs(got-c.want) > 1e-12 {
+			t.Errorf("%s: %g, want %g", c.name, got, c.want)
+		}
+	}
+	if len(trades.Returns) != 2 || trades.Trades != 2 {
+		t.Fatalf("%d returns and %d trades, want 2", len(trades.Returns), trades.Trades)
+	}
+	if c := trades.Copy(); len(c.Returns) != 0 {
+		t.Errorf("copy keeps %d returns", len(c.Returns))
+	}
+}
+
+func TestSortBestFitness(t *testing.T) {
+	g := InitGenetic(GeneticConf{Hours: 24, TradesByDay: 2})
+	for _, diff := range []float64{1, 3, math.NaN(), 2} {
+		g.AddNet(&NetPerc{DiffPerce: diff})
+	}
+	g.sortBest()
+	for i, want := range []float64{1.5, 1, 0.5} {
+		if g.Nets[i].Score != want {
+			t.Errorf("net %d scores %g, want %g", i, g.Nets[i].Score, want)
+		}
+	}
+	if !math.IsNaN(g.Nets[3].Score) {
+		t.Errorf("NaN sorted to %g", g.Nets[3].Score)
+	}
+
+	g.SetFitness(func(n *NetPerc) float64 { return -n.DiffPerce })
+	g.sortBest()
+	if g.GetBest().DiffPerce != 1 {
+		t.Errorf("custom fitness picked %g", g.GetBest().DiffPerce)
+	}
+}
@@ -0,0 +1,565 @@
+package neuro
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// FormatVersion is the version of the files written by NetPerc.Save and
+// Genetic.Save. Files without a version are the raw struct dumps of older
+// releases and are migrated on load. Since version 2 files carry a SHA-256
+// checksum which is verified on load.
+const FormatVersion = 2
+
+const checksumVersion = 2
+
+// netFile is the saved form of a NetPerc. Data and the per run state of
+// training are left out, the optimizer state is kept so training can go on.
+type netFile struct {
+	Version     int      `json:"version"`
+	Checksum    string   `json:"checksum"`
+	Arch        netArch  `json:"arch"`
+	Activations []string `json:"activations"`
+	Weights     []*Layer `json:"weights"`
+	Norm        *Norm    `json:"norm,omitempty"`
+	Train       netTrain `json:"train"`
+	Meta        netMeta  `json:"meta"`
+}
+
+type netArch struct {
+	Layers   int         `json:"layers"`
+	Neurons  int         `json:"neurons"`
+	Inps     int         `json:"inps"`
+	Outs     int         `json:"outs"`
+	Bias     bool        `json:"bias"`
+	FinalAct bool        `json:"final_act"`
+	Softmax  bool        `json:"softmax"`
+	Regress  bool        `json:"regress"`
+	Specs    []LayerSpec `json:"specs,omitempty"`
+}
+
+type netTrain struct {
+	Iters       int            `json:"iters"`
+	LearnRate   float64        `json:"learn_rate"`
+	RandWeights []float64      `json:"random_weights"`
+	Loss        string         `json:"loss,omitempty"`
+	Optimizer   OptimizerState `json:"optimizer"`
+	Conf        TrainConf      `json:"conf"`
+	Epoch       int            `json:"epoch"`
+	BestEpoch   int            `json:"best_epoch"`
+	ValidError  metaFloat      `json:"valid_error"`
+	Sigma       float64        `json:"sigma,omitempty"`
+}
+
+type netMeta struct {
+	Saved     time.Time `json:"saved"`
+	Error     metaFloat `json:"error"`
+	Score     metaFloat `json:"score"`
+	Result    Result    `json:"result"`
+	Budget    metaFloat `json:"budget"`
+	DiffPerce metaFloat `json:"diff_perce"`
+	Trades    int       `json:"trades"`
+	Nols      int       `json:"nols"`
+}
+
+type genFile struct {
+	Version   int         `json:"version"`
+	Checksum  string      `json:"checksum"`
+	Nets      []*netFile  `json:"nets"`
+	ResOrders []*ResOrder `json:"res_orders"`
+	Config    GeneticConf `json:"conf"`
+	Percent   metaFloat   `json:"percent"`
+	Error     metaFloat   `json:"error"`
+	Score     metaFloat   `json:"score"`
+	Step      float64     `json:"step"`
+	Successes int         `json:"successes"`
+	Tries     int         `json:"tries"`
+	Iters     int         `json:"iters"`
+	LBOitem   *LBO        `json:"lbo_item"`
+	Tm        time.Time   `json:"tm"`
+	HasBest   bool        `json:"has_best"`
+	Rand      *uint64     `json:"rand,omitempty"`
+}
+
+type cmaFile struct {
+	Version  int       `json:"version"`
+	Checksum string    `json:"checksum"`
+	Base     *netFile  `json:"base"`
+	Best     *netFile  `json:"best,omitempty"`
+	Config   CMAConf   `json:"conf"`
+	Mean     []float64 `json:"mean"`
+	Diag     []float64 `json:"diag"`
+	Ps       []float64 `json:"ps"`
+	Pc       []float64 `json:"pc"`
+	Sigma    float64   `json:"sigma"`
+	Iters    int       `json:"iters"`
+	Score    metaFloat `json:"score"`
+	Error    metaFloat `json:"error"`
+	HasBest  bool      `json:"has_best"`
+	Rand     *uint64   `json:"rand,omitempty"`
+}
+
+func (n *NetPerc) file() *netFile {
+	n.prepare()
+	return &netFile{
+		Version: FormatVersion,
+		Arch: netArch{
+			Layers:   n.Layers,
+			Neurons:  n.Neurons,
+			Inps:     n.Inps,
+			Outs:     n.Outs,
+			Bias:     n.Bias,
+			FinalAct: n.FinalAct,
+			Softmax:  n.Softmax,
+			Regress:  n.Regress,
+			Specs:    n.Specs,
+		},
+		Activations: n.Activations,
+		Weights:     n.Dense,
+		Norm:        n.Norm,
+		Train: netTrain{
+			Iters:       n.Iters,
+			LearnRate:   n.LearnRate,
+			RandWeights: n.RandWeights,
+			Loss:        n.LossName,
+			Optimizer:   n.Optim,
+			Conf:        n.TrainConf,
+			Epoch:       n.Epoch,
+			BestEpoch:   n.BestEpoch,
+			ValidError:  metaFloat(n.ValidError),
+			Sigma:       n.Sigma,
+		},
+		Meta: netMeta{
+			Saved:     time.Now(),
+			Error:     metaFloat(n.Error),
+			Score:     metaFloat(n.Score),
+			Result:    n.Result,
+			Budget:    metaFloat(n.Budget),
+			DiffPerce: metaFloat(n.DiffPerce),
+			Trades:    n.Trades,
+			Nols:      n.Nols,
+		},
+	}
+}
+
+func (f *netFile) net() *NetPerc {
+	return &NetPerc{
+		Layers:      f.Arch.Layers,
+		Neurons:     f.Arch.Neurons,
+		Inps:        f.Arch.Inps,
+		Outs:        f.Arch.Outs,
+		Bias:        f.Arch.Bias,
+		FinalAct:    f.Arch.FinalAct,
+		Softmax:     f.Arch.Softmax,
+		Regress:     f.Arch.Regress,
+		Specs:       f.Arch.Specs,
+		Activations: f.Activations,
+		Dense:       f.Weights,
+		Norm:        f.Norm,
+		Iters:       f.Train.Iters,
+		LearnRate:   f.Train.LearnRate,
+		RandWeights: f.Train.RandWeights,
+		LossName:    f.Train.Loss,
+		Optim:       f.Train.Optimizer,
+		TrainConf:   f.Train.Conf,
+		Epoch:       f.Train.Epoch,
+		BestEpoch:   f.Train.BestEpoch,
+		ValidError:  float64(f.Train.ValidError),
+		Sigma:       f.Train.Sigma,
+		Error:       float64(f.Meta.Error),
+		Score:       float64(f.Meta.Score),
+		Result:      f.Meta.Result,
+		Budget:      float64(f.Meta.Budget),
+		DiffPerce:   float64(f.Meta.DiffPerce),
+		Trades:      f.Meta.Trades,
+		Nols:        f.Meta.Nols,
+	}
+}
+
+// legacyNet turns an unversioned dump back into a file. The old [][]*Perc
+// layout is converted to dense layers on the way.
+func legacyNet(bts []byte) (*netFile, error) {
+	var net NetPerc
+	if err := json.Unmarshal(bts, &net); err != nil {
+		return nil, err
+	}
+	if len(net.Dense) == 0 && len(net.Net) < 2 {
+		return nil, errors.New("no layers in net")
+	}
+	if err := net.checkLegacy(); err != nil {
+		return nil, err
+	}
+	return net.file(), nil
+}
+
+// checkLegacy makes sure every neuron of an old dump has a weight for each
+// neuron of the next layer, percsToLayers would silently zero the rest.
+func (n *NetPerc) checkLegacy() error {
+	if len(n.Dense) > 0 {
+		return nil
+	}
+	for il, layer := range n.Net {
+		for _, p := range layer {
+			if p == nil {
+				return fmt.Errorf("layer %d: missing neuron", il)
+			}
+		}
+	}
+	for il := 0; il < len(n.Net)-1; il++ {
+		next := countPercs(n.Net[il+1])
+		for _, p := range n.Net[il] {
+			if len(p.Weights) < next {
+				return fmt.Errorf("layer %d: %d weights for %d neurons", il, len(p.Weights), next)
+			}
+		}
+	}
+	return nil
+}
+
+func (n *NetPerc) encode() ([]byte, error) {
+	if err := n.checkNames(); err != nil {
+		return nil, err
+	}
+	f := n.file()
+	return sealJSON(func(sum string) ([]byte, error) {
+		f.Checksum = sum
+		return json.Marshal(f)
+	})
+}
+
+func decodeNet(bts []byte) (*NetPerc, error) {
+	var head struct {
+		Version  int    `json:"version"`
+		Checksum string `json:"checksum"`
+	}
+	if err := json.Unmarshal(bts, &head); err != nil {
+		return nil, err
+	}
+	if err := verifyJSON(bts, head.Version, head.Checksum); err != nil {
+		return nil, err
+	}
+	var f *netFile
+	switch {
+	case head.Version == 0:
+		var err error
+		if f, err = legacyNet(bts); err != nil {
+			return nil, err
+		}
+	case head.Version > FormatVersion:
+		return nil, fmt.Errorf("unsupported format version %d", head.Version)
+	default:
+		f = &netFile{}
+		if err := json.Unmarshal(bts, f); err != nil {
+			return nil, err
+		}
+	}
+	return f.load()
+}
+
+func (f *netFile) load() (*NetPerc, error) {
+	net := f.net()
+	if err := net.validate(); err != nil {
+		return nil, err
+	}
+	net.prepare()
+	return net, nil
+}
+
+// validate checks a loaded net before it is used, so a damaged file is
+// reported here instead of panicking in the first forward pass.
+func (n *NetPerc) validate() error {
+	if len(n.Dense) == 0 {
+		return errors.New("no layers in net")
+	}
+	for il, l := range n.Dense {
+		switch {
+		case l == nil:
+			return fmt.Errorf("layer %d: missing", il)
+		case l.In < 1 || l.Out < 1:
+			return fmt.Errorf("layer %d: bad size %dx%d", il, l.In, l.Out)
+		case len(l.Weights) != l.In*l.Out:
+			return fmt.Errorf("layer %d: %d weights for %dx%d", il, len(l.Weights), l.In, l.Out)
+		case len(l.Biases) != l.Out && (l.Bias || len(l.Biases) != 0):
+			return fmt.Errorf("layer %d: %d biases for %d neurons", il, len(l.Biases), l.Out)
+		case il > 0 && l.In != n.Dense[il-1].Out:
+			return fmt.Errorf("layer %d: %d inputs after %d outputs", il, l.In, n.Dense[il-1].Out)
+		}
+		if !finite(l.Weights) || !finite(l.Biases) {
+			return fmt.Errorf("layer %d: weight is not a number", il)
+		}
+	}
+	first, last := n.Dense[0], n.Dense[len(n.Dense)-1]
+	if n.Inps > 0 && n.Inps != first.In {
+		return fmt.Errorf("net has %d inputs, first layer %d", n.Inps, first.In)
+	}
+	if n.Outs > 0 && n.Outs != last.Out {
+		return fmt.Errorf("net has %d outputs, last layer %d", n.Outs, last.Out)
+	}
+	if len(n.Activations) > 0 && len(n.Activations) != len(n.Dense) {
+		return fmt.Errorf("%d activations for %d layers", len(n.Activations), len(n.Dense))
+	}
+	if err := n.checkNames(); err != nil {
+		return err
+	}
+	if n.Norm != nil {
+		if len(n.Norm.Mean) != first.In || len(n.Norm.Std) != first.In {
+			return fmt.Errorf("norm has %d/%d values for %d inputs", len(n.Norm.Mean), len(n.Norm.Std), first.In)
+		}
+		for _, s := range n.Norm.Std {
+			if s == 0 {
+				return errors.New("norm has a zero std")
+			}
+		}
+	}
+	if n.Sigma < 0 || !finite([]float64{n.Sigma}) {
+		return fmt.Errorf("bad sigma %v", n.Sigma)
+	}
+	return nil
+}
+
+func finite(fls []float64) bool {
+	for _, f := range fls {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// metaFloat is a score or error in a file. Scores can be NaN or infinite,
+// for example with no trades, which JSON numbers cannot hold, so those are
+// written as strings.
+type metaFloat float64
+
+func (f metaFloat) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return json.Marshal(strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	return json.Marshal(v)
+}
+
+func (f *metaFloat) UnmarshalJSON(bts []byte) error {
+	if len(bts) > 0 && bts[0] == '"' {
+		var str string
+		if err := json.Unmarshal(bts, &str); err != nil {
+			return err
+		}
+		v, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return err
+		}
+		*f = metaFloat(v)
+		return nil
+	}
+	return json.Unmarshal(bts, (*float64)(f))
+}
+
+func (g *Genetic) file() *genFile {
+	f := &genFile{
+		Version:   FormatVersion,
+		ResOrders: g.ResOrders,
+		Config:    g.Config,
+		Percent:   metaFloat(g.Percent),
+		Error:     metaFloat(g.Error),
+		Score:     metaFloat(g.Score),
+		Step:      g.Step,
+		Successes: g.successes,
+		Tries:     g.tries,
+		Iters:     g.Iters,
+		LBOitem:   g.LBOitem,
+		Tm:        g.Tm,
+		HasBest:   g.hasBest,
+	}
+	if g.src != nil {
+		state := g.src.state
+		f.Rand = &state
+	}
+	f.Config.Data = nil
+	for _, n := range g.Nets {
+		f.Nets = append(f.Nets, n.file())
+	}
+	return f
+}
+
+func (g *Genetic) encode() ([]byte, error) {
+	for i, n := range g.Nets {
+		if err := n.checkNames(); err != nil {
+			return nil, fmt.Errorf("net %d: %v", i, err)
+		}
+	}
+	f := g.file()
+	return sealJSON(func(sum string) ([]byte, error) {
+		f.Checksum = sum
+		return json.Marshal(f)
+	})
+}
+
+func decodeGenetic(bts []byte) (*genFile, []*NetPerc, error) {
+	var head struct {
+		Version  int               `json:"version"`
+		Checksum string            `json:"checksum"`
+		Nets     []json.RawMessage `json:"nets"`
+	}
+	if err := json.Unmarshal(bts, &head); err != nil {
+		return nil, nil, err
+	}
+	if head.Version > FormatVersion {
+		return nil, nil, fmt.Errorf("unsupported format version %d", head.Version)
+	}
+	if err := verifyJSON(bts, head.Version, head.Checksum); err != nil {
+		return nil, nil, err
+	}
+	var f genFile
+	if head.Version == 0 {
+		// the nets of old dumps are raw NetPerc values, they are decoded
+		// one by one below
+		var legacy struct {
+			genFile
+			Nets json.RawMessage `json:"nets"`
+		}
+		if err := json.Unmarshal(bts, &legacy); err != nil {
+			return nil, nil, err
+		}
+		f = legacy.genFile
+		f.Config.Data = nil
+	} else if err := json.Unmarshal(bts, &f); err != nil {
+		return nil, nil, err
+	}
+	if _, err := CrossoverByName(f.Config.Crossover); err != nil {
+		return nil, nil, err
+	}
+	if _, err := SelectionByName(f.Config.Selection); err != nil {
+		return nil, nil, err
+	}
+	if _, err := MutationByName(f.Config.Mutation); err != nil {
+		return nil, nil, err
+	}
+	nets := make([]*NetPerc, len(head.Nets))
+	for i, raw := range head.Nets {
+		var (
+			net *NetPerc
+			err error
+		)
+		switch {
+		case head.Version == 0:
+			net, err = decodeNet(raw)
+		case f.Nets[i] == nil:
+			err = errors.New("missing")
+		default:
+			net, err = f.Nets[i].load()
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("net %d: %v", i, err)
+		}
+		nets[i] = net
+	}
+	return &f, nets, nil
+}
+
+func (c *CMA) encode() ([]byte, error) {
+	f := &cmaFile{
+		Version: FormatVersion,
+		Base:    c.base.file(),
+		Config:  c.Config,
+		Mean:    c.Mean,
+		Diag:    c.Diag,
+		Ps:      c.Ps,
+		Pc:      c.Pc,
+		Sigma:   c.Sigma,
+		Iters:   c.Iters,
+		Score:   metaFloat(c.Score),
+		Error:   metaFloat(c.Error),
+		HasBest: c.hasBest,
+	}
+	if c.best != nil {
+		f.Best = c.best.file()
+	}
+	if c.src != nil {
+		state := c.src.state
+		f.Rand = &state
+	}
+	return sealJSON(func(sum string) ([]byte, error) {
+		f.Checksum = sum
+		return json.Marshal(f)
+	})
+}
+
+func decodeCMA(bts []byte) (*cmaFile, *NetPerc, *NetPerc, error) {
+	var f cmaFile
+	if err := json.Unmarshal(bts, &f); err != nil {
+		return nil, nil, nil, err
+	}
+	if f.Version < checksumVersion || f.Version > FormatVersion {
+		return nil, nil, nil, fmt.Errorf("unsupported format version %d", f.Version)
+	}
+	if err := verifyJSON(bts, f.Version, f.Checksum); err != nil {
+		return nil, nil, nil, err
+	}
+	if f.Base == nil {
+		return nil, nil, nil, errors.New("missing base net")
+	}
+	base, err := f.Base.load()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("base: %v", err)
+	}
+	var best *NetPerc
+	if f.Best != nil {
+		if best, err = f.Best.load(); err != nil {
+			return nil, nil, nil, fmt.Errorf("best: %v", err)
+		}
+	}
+	size := genes(base)
+	for _, fls := range [][]float64{f.Mean, f.Diag, f.Ps, f.Pc} {
+		if len(fls) != size || !finite(fls) {
+			return nil, nil, nil, fmt.Errorf("state does not fit %d weights", size)
+		}
+	}
+	for _, d := range f.Diag {
+		if d <= 0 {
+			return nil, nil, nil, errors.New("variance not positive")
+		}
+	}
+	if !(f.Sigma > 0) || math.IsInf(f.Sigma, 0) || f.Config.Lambda < 2 {
+		return nil, nil, nil, errors.New("bad step or population")
+	}
+	return &f, base, best, nil
+}
+
+// sealJSON marshals a file twice: first with an empty checksum to hash it,
+// then with the hash filled in.
+func sealJSON(marshal func(sum string) ([]byte, error)) ([]byte, error) {
+	bts, err := marshal("")
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(bts)
+	return marshal(hex.EncodeToString(sum[:]))
+}
+
+// verifyJSON undoes sealJSON on the raw bytes, so the check does not depend
+// on marshalling the decoded file the same way again.
+func verifyJSON(bts []byte, version int, sum string) error {
+	if version < checksumVersion {
+		return nil
+	}
+	if sum == "" {
+		return errors.New("missing checksum")
+	}
+	field := []byte(`"checksum":"` + sum + `"`)
+	if !bytes.Contains(bts, field) {
+		return errors.New("checksum mismatch")
+	}
+	got := sha256.Sum256(bytes.Replace(bts, field, []byte(`"checksum":""`), 1))
+	if hex.EncodeToString(got[:]) != sum {
+		return errors.New("checksum mismatch")
+	}
+	return nil
+}
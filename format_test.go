@@ -0,0 +1,207 @@
+package neuro
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// The legacy_net fixtures are Save dumps of the release before the file
+// format, with the outputs that release predicted for a few inputs.
+var legacyFixtures = []string{"legacy_net", "legacy_net_bias"}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	bts, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bts
+}
+
+func TestLoadLegacy(t *testing.T) {
+	for _, name := range legacyFixtures {
+		n, err := LoadNet(filepath.Join("testdata", name+".json"))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var want struct {
+			Inputs  [][]float64 `json:"inputs"`
+			Outputs [][]float64 `json:"outputs"`
+		}
+		if err := json.Unmarshal(readFixture(t, name+".out.json"), &want); err != nil {
+			t.Fatal(err)
+		}
+		for i, inputs := range want.Inputs {
+			got := n.PredictProba(inputs)
+			for k := range got {
+				if got[k] != want.Outputs[i][k] {
+					t.Fatalf("%s: predicts %v for %v, the old release %v", name, got, inputs, want.Outputs[i])
+				}
+			}
+		}
+	}
+}
+
+// writeLegacy writes the fixture name with its neurons changed by edit.
+func writeLegacy(t *testing.T, name string, edit func(net [][]*Perc) [][]*Perc) string {
+	t.Helper()
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(readFixture(t, name+".json"), &raw); err != nil {
+		t.Fatal(err)
+	}
+	var net [][]*Perc
+	if err := json.Unmarshal(raw["net"], &net); err != nil {
+		t.Fatal(err)
+	}
+	bts, err := json.Marshal(edit(net))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw["net"] = bts
+	if bts, err = json.Marshal(raw); err != nil {
+		t.Fatal(err)
+	}
+	fileName := filepath.Join(t.TempDir(), name+".json")
+	if err := ioutil.WriteFile(fileName, bts, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+func TestLoadLegacyRejects(t *testing.T) {
+	damaged := filepath.Join(t.TempDir(), "damaged.json")
+	bts := readFixture(t, "legacy_net.json")
+	if err := ioutil.WriteFile(damaged, bts[:len(bts)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"damaged": damaged,
+		"one layer": writeLegacy(t, "legacy_net", func(net [][]*Perc) [][]*Perc {
+			return net[:1]
+		}),
+		"short weights": writeLegacy(t, "legacy_net", func(net [][]*Perc) [][]*Perc {
+			net[1][0].Weights = net[1][0].Weights[:2]
+			return net
+		}),
+		"missing neuron": writeLegacy(t, "legacy_net", func(net [][]*Perc) [][]*Perc {
+			net[2][1] = nil
+			return net
+		}),
+		"missing output": writeLegacy(t, "legacy_net_bias", func(net [][]*Perc) [][]*Perc {
+			net[len(net)-1][0] = nil
+			return net
+		}),
+		"short bias weights": writeLegacy(t, "legacy_net_bias", func(net [][]*Perc) [][]*Perc {
+			bias := net[0][len(net[0])-1]
+			bias.Weights = bias.Weights[:1]
+			return net
+		}),
+	}
+	for name, fileName := range files {
+		if _, err := LoadNet(fileName); err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	edits := map[string]func(f *netFile){
+		"no layers":     func(f *netFile) { f.Weights = nil },
+		"missing layer": func(f *netFile) { f.Weights[1] = nil },
+		"short weights": func(f *netFile) { f.Weights[0].Weights = f.Weights[0].Weights[:1] },
+		"short biases":  func(f *netFile) { f.Weights[0].Biases = f.Weights[0].Biases[:1] },
+		"inputs":        func(f *netFile) { f.Arch.Inps = 5 },
+		"outputs":       func(f *netFile) { f.Arch.Outs = 5 },
+		"activations":   func(f *netFile) { f.Activations = []string{"sigmoid"} },
+		"unknown act":   func(f *netFile) { f.Activations = []string{"sigmoid", "cube"} },
+		"norm size":     func(f *netFile) { f.Norm = &Norm{Mean: []float64{0}, Std: []float64{1}} },
+		"norm zero std": func(f *netFile) { f.Norm = &Norm{Mean: []float64{0, 0}, Std: []float64{1, 0}} },
+		"layers not fit": func(f *netFile) {
+			l := f.Weights[1]
+			l.In++
+			l.Weights = make([]float64, l.In*l.Out)
+		},
+	}
+	for name, edit := range edits {
+		f := InitNetPerc(1, 3).SetBias(true).CreateNet(xorData, 1).file()
+		edit(f)
+		if _, err := f.load(); err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+}
+
+// tamper changes the first digit after key, keeping the JSON valid.
+func tamper(t *testing.T, bts []byte, key string) []byte {
+	t.Helper()
+	bts = append([]byte(nil), bts...)
+	i := bytes.Index(bts, []byte(key))
+	if i < 0 {
+		t.Fatalf("no %s in the file", key)
+	}
+	for i += len(key); i < len(bts); i++ {
+		if c := bts[i]; c >= '0' && c <= '9' {
+			if c == '9' {
+				bts[i] = '8'
+			} else {
+				bts[i] = c + 1
+			}
+			return bts
+		}
+	}
+	t.Fatalf("no digit after %s", key)
+	return nil
+}
+
+var checksumField = regexp.MustCompile(`"checksum":"[0-9a-f]+"`)
+
+func TestChecksum(t *testing.T) {
+	var buf bytes.Buffer
+	if err := InitNetPerc(1, 4).SetWeight(-1, 1).CreateNet(xorData, 1).Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"tampered": tamper(t, buf.Bytes(), `"weights":`),
+		"missing":  checksumField.ReplaceAll(buf.Bytes(), []byte(`"checksum":""`)),
+		"removed":  checksumField.ReplaceAll(buf.Bytes(), []byte(`"checksum_":""`)),
+	}
+	for name, bts := range files {
+		err := (&NetPerc{}).Decode(bytes.NewReader(bts))
+		if err == nil || !strings.Contains(err.Error(), "checksum") {
+			t.Errorf("%s checksum: got %v", name, err)
+		}
+	}
+	if err := (&NetPerc{}).Decode(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGeneticChecksum(t *testing.T) {
+	g := InitGenetic(GeneticConf{Population: 4, LastBest: 2})
+	for i := 0; i < 2; i++ {
+		g.AddNet(InitNetPerc(1, 4).SetWeight(-1, 1).CreateNet(xorData, 1))
+	}
+	var buf bytes.Buffer
+	if err := g.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"tampered net": tamper(t, buf.Bytes(), `"weights":`),
+		"tampered":     tamper(t, buf.Bytes(), `"iters":`),
+		"missing":      checksumField.ReplaceAll(buf.Bytes(), []byte(`"checksum":""`)),
+	}
+	for name, bts := range files {
+		err := InitGenetic().Decode(bytes.NewReader(bts))
+		if err == nil || !strings.Contains(err.Error(), "checksum") {
+			t.Errorf("%s checksum: got %v", name, err)
+		}
+	}
+	if err := InitGenetic().Decode(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,17 +1,19 @@
 package neuro
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"math"
+	"math/rand"
+	"os"
 	"sort"
 	"sync"
 	"time"
-
-	"github.com/thoas/go-funk"
 )
 
 type Genetic struct {
@@ -21,9 +23,21 @@ type Genetic struct {
 	Percent   float64     `json:"percent"`
 	Error     float64     `json:"error"`
 	Score     float64     `json:"score"`
+	Step      float64     `json:"step"`
 	Iters     int         `json:"iters"`
 	LBOitem   *LBO        `json:"lbo_item"`
 	Tm        time.Time   `json:"tm"`
+	Callbacks []Callback  `json:"-"`
+	Fitness   Fitness     `json:"-"`
+	Err       error       `json:"-"`
+	hasBest   bool
+	src       *splitMix
+	rng       *rand.Rand
+	cross     Crossover
+	sel       Selection
+	mut       Mutation
+	successes int
+	tries     int
 }
 
 type GeneticConf struct {
@@ -42,7 +56,31 @@ type GeneticConf struct {
 	TradesByDay    float64      `json:"trades_by_day"`
 	Hours          float64      `json:"hours"`
 	MinPerce       float64      `json:"min_perce"`
-	Data           []*DataTeach `json:"data"`
+	Data           []*DataTeach `json:"data,omitempty"`
+
+	// Seed of the run, see SetSeed. With CheckpointEvery set the run is
+	// saved to CheckpointFile every that many generations.
+	Seed            int64  `json:"seed"`
+	CheckpointEvery int    `json:"checkpoint_every"`
+	CheckpointFile  string `json:"checkpoint_file"`
+
+	// CrossoverRate is the share of children that combine two survivors
+	// with the Crossover operator, see SetCrossover, before mutation.
+	CrossoverRate float64 `json:"crossover_rate"`
+	Crossover     string  `json:"crossover"`
+
+	// Selection picks the parents among the survivors, see SetSelection.
+	// With Elitism set only that many of the best survivors are kept
+	// unchanged and the rest of the population is bred, without it every
+	// survivor is kept.
+	Selection string `json:"selection"`
+	Elitism   int    `json:"elitism"`
+
+	// Mutation is the operator children are mutated with, see SetMutation.
+	// With StepWindow set Iterate adapts its step by the 1/5th success
+	// rule over that many generations.
+	Mutation   string `json:"mutation"`
+	StepWindow int    `json:"step_window"`
 }
 
 type LBO struct {
@@ -129,8 +167,26 @@ func (g *Genetic) GenerateOrdersV2() *Genetic {
 	return g
 }
 
+// FirstMutate searches orders until *gsfd is set.
+//
+// Deprecated: reading *gsfd while another goroutine sets it is a data race,
+// use FirstMutateCtx.
 func (g *Genetic) FirstMutate(inpData []DataTeach, gsfd *bool) *Genetic {
-	g.LBOitem = &LBO{Trades: []int{}}
+	g.firstMutate(context.Background(), inpData, func() bool { return *gsfd })
+	return g
+}
+
+// FirstMutateCtx searches orders until ctx is done. The best orders found
+// so far stay in g.LBOitem and ctx.Err() is returned.
+func (g *Genetic) FirstMutateCtx(ctx context.Context, inpData []DataTeach) (*Genetic, error) {
+	err := g.firstMutate(ctx, inpData, nil)
+	return g, err
+}
+
+func (g *Genetic) firstMutate(ctx context.Context, inpData []DataTeach, stop func() bool) error {
+	if g.LBOitem == nil {
+		g.LBOitem = &LBO{Trades: []int{}}
+	}
 	for {
 		var wg sync.WaitGroup
 		wg.Add(len(g.ResOrders))
@@ -156,32 +212,37 @@ func (g *Genetic) FirstMutate(inpData []DataTeach, gsfd *bool) *Genetic {
 		}
 		wg.Wait()
 		g.sortBestOrders()
-		if g.Score < g.GetBestOrders().Score {
+		newBest := g.Score < g.GetBestOrders().Score
+		if newBest {
 			g.LBOitem.Count = g.GetBestOrders().Count
 			g.LBOitem.Score = g.GetBestOrders().Score
 			g.LBOitem.Trades = g.GetBestOrders().Trades
 			g.Score = g.GetBestOrders().Score
-			g.LogScoreOrders(1, " !!! BEST !!! ")
-		} else {
-			g.LogScoreOrders(10000, " !!! TIME !!! ")
 		}
-		if *gsfd {
-			break
+		if err := g.emitGeneration(g.orderMetrics(), newBest, LogCallback{Every: 10000}); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if stop != nil && stop() {
+			return nil
 		}
 
 		g.mutateOrdersFirst()
 		g.Iters += 1
+		if err := g.checkpoint(); err != nil {
+			return err
+		}
 
 		//time.Sleep(time.Second / 10)
 
 	}
-
-	return g
 }
 
 func (g *Genetic) GenerateOrders(maxLength int) *Genetic {
 	for _, r := range g.ResOrders {
-		r.Count = randIntMin(1, int(maxLength/2))
+		r.Count = randIntMin(g.random(), 1, int(maxLength/2))
 		r.Trades = []int{}
 		r.By = int(math.Floor(float64(maxLength / r.Count)))
 		for i := 0; i < maxLength-1; i++ {
@@ -196,7 +257,7 @@ func (g *Genetic) GenerateOrders(maxLength int) *Genetic {
 
 func (g *Genetic) AddOrder() *ResOrder {
 	r := ResOrder{}
-	r.Count = randIntMin(1, int(g.Config.Inps))
+	r.Count = randIntMin(g.random(), 1, int(g.Config.Inps))
 	r.Trades = []int{}
 	r.By = int(math.Floor(float64(g.Config.Inps / r.Count)))
 	for i := 0; i < g.Config.Inps-1; i++ {
@@ -246,10 +307,9 @@ func (g *Genetic) ClearScore() {
 }
 
 func (g *Genetic) sortBest() *Genetic {
+	g.evaluate()
 	sort.Slice(g.Nets, func(i, j int) bool {
-		g.Nets[i].Score = g.Nets[i].DiffPerce / (g.Config.Hours/24*g.Config.TradesByDay + float64(g.Nets[i].Trades))
-		g.Nets[j].Score = g.Nets[j].DiffPerce / (g.Config.Hours/24*g.Config.TradesByDay + float64(g.Nets[j].Trades))
-		return g.Nets[i].Score > g.Nets[j].Score
+		return fitter(g.Nets[i].Score, g.Nets[j].Score)
 	})
 	return g
 }
@@ -267,7 +327,7 @@ func (g *Genetic) AddPopulations() *Genetic {
 	var s int
 	for i := 0; i < g.Config.Population; i++ {
 		next := g.ResOrders[s].CopyJson()
-		g.ResOrders = append(g.ResOrders, next.mutateV3(g.Config.Inps))
+		g.ResOrders = append(g.ResOrders, next.mutateV3(g.random(), g.Config.Inps))
 		s += 1
 	}
 	return g
@@ -316,12 +376,16 @@ func (g *Genetic) TrainItemOrders(inpData []DataTeach) {
 	wg.Wait()
 }
 
+// TrainItem scores every net with ret in its own goroutine. Each net gets
+// a random stream derived from the run's seed, see NetPerc.Random.
 func (g *Genetic) TrainItem(ret func(n *NetPerc)) {
+	rngs := g.forks(len(g.Nets))
 	var wg sync.WaitGroup
 	for i, _ := range g.Nets {
 		wg.Add(1)
 		go func(ind int) {
 			defer wg.Done()
+			g.Nets[ind].SetRand(rngs[ind])
 			g.Nets[ind].Trades = 0
 			g.Nets[ind].Nols = 0
 			g.Nets[ind].Score = 0
@@ -329,6 +393,7 @@ func (g *Genetic) TrainItem(ret func(n *NetPerc)) {
 			g.Nets[ind].LastPrice = 0
 			g.Nets[ind].DiffPerce = 0
 			g.Nets[ind].StatusBSell = false
+			g.Nets[ind].Returns = g.Nets[ind].Returns[:0]
 			ret(g.Nets[ind])
 		}(i)
 	}
@@ -336,12 +401,13 @@ func (g *Genetic) TrainItem(ret func(n *NetPerc)) {
 }
 
 func (g *Genetic) Train(last bool) {
+	rngs := g.forks(len(g.Nets))
 	var wg sync.WaitGroup
 	wg.Add(len(g.Nets))
 	for i, _ := range g.Nets {
 		go func(ind int) {
 			defer wg.Done()
-			g.Nets[ind].TrainIter()
+			g.Nets[ind].SetRand(rngs[ind]).TrainIter()
 		}(i)
 	}
 	wg.Wait()
@@ -349,11 +415,37 @@ func (g *Genetic) Train(last bool) {
 	g.sortBest()
 	g.sliceBest()
 
+	newBest := !g.hasBest || g.GetBest().Score > g.Score
+	g.hasBest = true
+	g.Score = g.GetBest().Score
+	g.Error = g.GetBest().Error
+	if err := g.emitGeneration(g.genMetrics(), newBest, nil); err != nil {
+		return
+	}
+
 	if !last {
-		g.mutate()
+		g.mutate(g.scores())
 	}
+	g.Iters += 1
+	g.checkpoint()
+}
 
-	g.Score = g.GetBest().Score
+// RunCtx repeats Train generations until the error of the best net drops
+// to Config.BestResult or ctx is done. It returns the best net so far.
+func (g *Genetic) RunCtx(ctx context.Context) (*NetPerc, error) {
+	if len(g.Nets) == 0 {
+		return nil, errors.New("no nets to train")
+	}
+	for g.Error > g.Config.BestResult {
+		if err := ctx.Err(); err != nil {
+			return g.GetBest(), err
+		}
+		g.Train(false)
+		if g.Err != nil {
+			return g.GetBest(), g.Err
+		}
+	}
+	return g.GetBest(), nil
 }
 
 func (g *Genetic) CheckScore() bool {
@@ -405,11 +497,17 @@ func (g *Genetic) sortBestNolsIters() {
 func (g *Genetic) Iterate() bool {
 	g.sortBest()
 	g.sliceBest()
+	newBest := !g.hasBest || g.GetBest().Budget > g.Score
+	g.adaptStep(g.hasBest && newBest)
+	g.hasBest = true
 	g.Score = g.GetBest().Budget
-	//g.mutate()
-	g.mutateV2()
+	if err := g.emitGeneration(g.genMetrics(), newBest, nil); err != nil {
+		return true
+	}
+	//g.mutate(g.scores())
+	g.mutateV2(g.scores())
 	g.Iters += 1
-	return false
+	return g.checkpoint() != nil
 }
 
 func (g *Genetic) IterateOrders() {
@@ -431,8 +529,12 @@ func (g *Genetic) IterateNols(lastNols int) bool {
 		return true
 	}
 	g.Iters += 1
-	g.mutate()
-	return false
+	fitness := make([]float64, len(g.Nets))
+	for i, n := range g.Nets {
+		fitness[i] = -float64(n.Nols)
+	}
+	g.mutate(fitness)
+	return g.checkpoint() != nil
 }
 
 func (g *Genetic) sliceBest() {
@@ -447,142 +549,200 @@ func (g *Genetic) sliceBestOrders() {
 	}
 }
 
-func (g *Genetic) mutate() {
-	var (
-		wg          sync.WaitGroup
-		mtWait      sync.Mutex
-		listNetsAdd []*NetPerc
-	)
+// mutate fills the population up with mutated children of the survivors,
+// whose parents are chosen on fitness by the selection. Every child gets its
+// own slot and random stream, so the result does not depend on the order the
+// goroutines finish in.
+func (g *Genetic) mutate(fitness []float64) {
 	ind := len(g.Nets) - 1
-	if ind == 0 {
-		for s := ind; s < g.Config.Population; s++ {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				n := g.Nets[0].Copy()
-				var wgw sync.WaitGroup
-				for i := 0; i < g.Config.LimitMutateSub; i++ {
-					wgw.Add(1)
-					go func(nn *NetPerc) {
-						defer wgw.Done()
-						nn.mutateWeight(g.Config.MinRandWeight, g.Config.MaxRandWeight)
-					}(n)
-				}
-				wgw.Wait()
-				mtWait.Lock()
-				listNetsAdd = append(listNetsAdd, n)
-				mtWait.Unlock()
-
-			}()
-		}
-	} else {
-		for s := ind; s < g.Config.Population; s++ {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				r := randIntMin(1, ind)
-				n := g.Nets[r].Copy()
-				var wgw sync.WaitGroup
-				for i := 0; i < g.Config.LimitMutateSub; i++ {
-					wgw.Add(1)
-					go func(nn *NetPerc) {
-						defer wgw.Done()
-						nn.mutateWeight(g.Config.MinRandWeight, g.Config.MaxRandWeight)
-					}(n)
-				}
-				wgw.Wait()
-				mtWait.Lock()
-				listNetsAdd = append(listNetsAdd, n)
-				mtWait.Unlock()
-
-			}()
-		}
+	if ind < 0 || ind >= g.Config.Population {
+		return
+	}
+	elite := g.elite()
+	listNetsAdd := make([]*NetPerc, g.Config.Population-ind+len(g.Nets)-elite)
+	rngs := g.forks(len(listNetsAdd))
+	parents := g.selection().Select(g.random(), fitness, 2*len(listNetsAdd))
+	cross, mut := g.crossover(), g.mutation()
+	var wg sync.WaitGroup
+	wg.Add(len(listNetsAdd))
+	for s := range listNetsAdd {
+		go func(s int) {
+			defer wg.Done()
+			rng := rngs[s]
+			n := g.breed(rng, cross, parents[2*s], parents[2*s+1])
+			g.mutateNet(rng, mut, n, g.Config.LimitMutateSub)
+			listNetsAdd[s] = n
+		}(s)
 	}
 	wg.Wait()
-	g.Nets = append(g.Nets, listNetsAdd...)
+	g.Nets = append(g.Nets[:elite], listNetsAdd...)
+}
+
+// elite is the number of survivors that go to the next generation as they
+// are.
+func (g *Genetic) elite() int {
+	if g.Config.Elitism > 0 && g.Config.Elitism < len(g.Nets) {
+		return g.Config.Elitism
+	}
+	return len(g.Nets)
 }
 
 func (g *Genetic) Save(fileName string) error {
 	if fileName == "" {
 		return errors.New("empty filename")
 	}
-	if bts, err := json.Marshal(g); err != nil {
+	return writeFile(fileName, g.Encode)
+}
+
+func (g *Genetic) Encode(w io.Writer) error {
+	bts, err := g.encode()
+	if err != nil {
 		return err
-	} else {
-		if err := ioutil.WriteFile(fileName, bts, 0644); err != nil {
-			return err
+	}
+	_, err = w.Write(bts)
+	return err
+}
+
+// Decode reads a population written by Encode or Save into g. The data set
+// and callbacks of g are kept.
+func (g *Genetic) Decode(r io.Reader) error {
+	bts, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	f, nets, err := decodeGenetic(bts)
+	if err != nil {
+		return err
+	}
+	data := g.Config.Data
+	g.Nets = nets
+	g.ResOrders = f.ResOrders
+	g.Config = f.Config
+	g.Config.Data = data
+	g.Percent = float64(f.Percent)
+	g.Error = float64(f.Error)
+	g.Score = float64(f.Score)
+	g.Step = f.Step
+	g.successes, g.tries = f.Successes, f.Tries
+	g.Iters = f.Iters
+	g.LBOitem = f.LBOitem
+	g.Tm = f.Tm
+	g.hasBest = f.HasBest
+	g.cross, g.sel, g.mut = nil, nil, nil
+	g.src, g.rng = nil, nil
+	if f.Rand != nil {
+		g.src = &splitMix{state: *f.Rand}
+		g.rng = rand.New(g.src)
+	}
+	return nil
+}
+
+// Resume loads a checkpoint into g and gives every net data, which is not
+// part of the file. With the same data the run continues exactly as if it
+// had not been stopped.
+func (g *Genetic) Resume(fileName string, data []DataTeach) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := g.Decode(f); err != nil {
+		return fmt.Errorf("resume %s: %v", fileName, err)
+	}
+	if data != nil {
+		for _, n := range g.Nets {
+			n.Data = data
 		}
 	}
 	return nil
 }
 
+// checkpoint saves the run every Config.CheckpointEvery generations, a
+// failed save is kept in g.Err.
+func (g *Genetic) checkpoint() error {
+	conf := g.Config
+	if conf.CheckpointEvery < 1 || conf.CheckpointFile == "" || g.Iters%conf.CheckpointEvery != 0 {
+		return nil
+	}
+	if err := g.Save(conf.CheckpointFile); err != nil {
+		g.Err = err
+		return err
+	}
+	return nil
+}
+
 func (g *Genetic) Load(fileName string) bool {
 	if fileName == "" {
 		log.Println("empty filename")
 		return false
 	}
-	bts, err := ioutil.ReadFile(fileName)
+	f, err := os.Open(fileName)
 	if err != nil {
 		log.Println(err)
 		return false
 	}
-	if err := json.Unmarshal(bts, &g); err != nil {
-		log.Println(err)
+	defer f.Close()
+	if err := g.Decode(f); err != nil {
+		log.Println("load "+fileName+":", err)
 		return false
 	}
 	return true
 }
 
-func (g *Genetic) mutateV2() {
-	var (
-		wg          sync.WaitGroup
-		mtWait      sync.Mutex
-		listNetsAdd []*NetPerc
-	)
+// mutateV2 breeds every child from the best net, the selection only picks
+// its mate for crossover.
+func (g *Genetic) mutateV2(fitness []float64) {
 	ind := len(g.Nets) - 1
-	for s := ind; s < g.Config.Population; s++ {
-		wg.Add(1)
-		go func() {
+	if ind < 0 || ind >= g.Config.Population {
+		return
+	}
+	elite := g.elite()
+	listNetsAdd := make([]*NetPerc, g.Config.Population-ind+len(g.Nets)-elite)
+	rngs := g.forks(len(listNetsAdd))
+	mates := g.selection().Select(g.random(), fitness, len(listNetsAdd))
+	cross, mut := g.crossover(), g.mutation()
+	var wg sync.WaitGroup
+	wg.Add(len(listNetsAdd))
+	for s := range listNetsAdd {
+		go func(s int) {
 			defer wg.Done()
-			n := g.Nets[0].Copy()
-			n.mutateWeight(g.Config.MinRandWeight, g.Config.MaxRandWeight)
-			mtWait.Lock()
-			listNetsAdd = append(listNetsAdd, n)
-			mtWait.Unlock()
-		}()
+			n := g.breed(rngs[s], cross, 0, mates[s])
+			g.mutateNet(rngs[s], mut, n, 1)
+			listNetsAdd[s] = n
+		}(s)
 	}
 	wg.Wait()
-	g.Nets = append(g.Nets, listNetsAdd...)
+	g.Nets = append(g.Nets[:elite], listNetsAdd...)
 }
 
 func (g *Genetic) MutateOrdersV3() {
 	for len(g.ResOrders) < g.Config.Population {
 		next := g.ResOrders[0].CopyJson()
 		for s := 0; s < g.Config.MaxMutateIter; s++ {
-			next.mutateV3(g.Config.Inps)
+			next.mutateV3(g.random(), g.Config.Inps)
 		}
 		g.ResOrders = append(g.ResOrders, next)
 	}
 }
 
 func (g *Genetic) mutateOrders() {
-	var (
-		wg         sync.WaitGroup
-		mtWait     sync.Mutex
-		listOrders []*ResOrder
-	)
 	ind := len(g.ResOrders) - 1
-	for s := ind; s < g.Config.Population; s++ {
-		wg.Add(1)
-		go func() {
+	count := g.Config.Population - ind
+	if count < 0 {
+		count = 0
+	}
+	listOrders := make([]*ResOrder, count)
+	rngs := g.forks(len(listOrders))
+	var wg sync.WaitGroup
+	wg.Add(len(listOrders))
+	for s := range listOrders {
+		go func(s int) {
 			defer wg.Done()
-			rnd := randIntMin(0, ind)
+			rnd := randIntMin(rngs[s], 0, ind)
 			r := g.ResOrders[rnd].Copy()
-			r.mutate()
-			mtWait.Lock()
-			listOrders = append(listOrders, r)
-			mtWait.Unlock()
-		}()
+			r.mutate(rngs[s])
+			listOrders[s] = r
+		}(s)
 	}
 	wg.Wait()
 	for i := 0; i < g.Config.NewItems; i++ {
@@ -617,11 +777,11 @@ func remove(s []int, i int) []int {
 	return s[:len(s)-1]
 }
 
-func (r *ResOrder) mutateAll(max int) {
+func (r *ResOrder) mutateAll(rng *rand.Rand, max int) {
 	mp := make(map[int]struct{})
 	trds := []int{}
 	for {
-		in := funk.RandomInt(0, max)
+		in := randRange(rng, 0, max)
 		if _, ok := mp[in]; ok {
 			continue
 		}
@@ -637,7 +797,7 @@ func (r *ResOrder) mutateAll(max int) {
 	r.Trades = trds
 }
 
-func (r *ResOrder) copyAndMutate(maxVal int) *ResOrder {
+func (r *ResOrder) copyAndMutate(rng *rand.Rand, maxVal int) *ResOrder {
 	var res *ResOrder
 	bts, err := json.Marshal(r)
 	if err != nil {
@@ -646,20 +806,20 @@ func (r *ResOrder) copyAndMutate(maxVal int) *ResOrder {
 	if err := json.Unmarshal(bts, &res); err != nil {
 		log.Fatal(err)
 	}
-	return res.mutateV3(maxVal)
+	return res.mutateV3(rng, maxVal)
 }
 
-func (r *ResOrder) mutateV3(maxVal int) *ResOrder {
-	rnd := funk.RandomInt(0, len(r.Trades))
+func (r *ResOrder) mutateV3(rng *rand.Rand, maxVal int) *ResOrder {
+	rnd := randRange(rng, 0, len(r.Trades))
 	var nVal int
 	switch rnd {
 	case 0:
-		nVal = funk.RandomInt(0, r.Trades[1])
+		nVal = randRange(rng, 0, r.Trades[1])
 	case len(r.Trades) - 1:
-		nVal = funk.RandomInt(r.Trades[len(r.Trades)-2], maxVal)
+		nVal = randRange(rng, r.Trades[len(r.Trades)-2], maxVal)
 	default:
 		if r.Trades[rnd-1]+1 < r.Trades[rnd+1] {
-			nVal = funk.RandomInt(r.Trades[rnd-1]+1, r.Trades[rnd+1])
+			nVal = randRange(rng, r.Trades[rnd-1]+1, r.Trades[rnd+1])
 		} else {
 			nVal = r.Trades[rnd-1] + 1
 		}
@@ -668,38 +828,39 @@ func (r *ResOrder) mutateV3(maxVal int) *ResOrder {
 	return r
 }
 
-func (r *ResOrder) mutate() {
+func (r *ResOrder) mutate(rng *rand.Rand) {
 	var nVal int
-	rand := randIntMin(0, len(r.Trades)-1)
+	rand := randIntMin(rng, 0, len(r.Trades)-1)
 	if rand == 0 {
 		if len(r.Trades) != 1 {
-			nVal = randIntMin(0, r.Trades[rand+1]-1)
+			nVal = randIntMin(rng, 0, r.Trades[rand+1]-1)
 		}
 	}
 	if rand == len(r.Trades)-1 {
 		if len(r.Trades) != 1 {
-			nVal = randIntMin(r.Trades[rand-1], r.Trades[rand])
+			nVal = randIntMin(rng, r.Trades[rand-1], r.Trades[rand])
 		}
 	}
 	if rand != 0 && rand != len(r.Trades)-1 {
 		if len(r.Trades) != 1 {
-			nVal = randIntMin(r.Trades[rand-1], r.Trades[rand+1])
+			nVal = randIntMin(rng, r.Trades[rand-1], r.Trades[rand+1])
 		}
 	}
 	r.Trades[rand] = nVal
 }
 
 func (g *Genetic) mutateOrdersFirst() {
+	rngs := g.forks(len(g.ResOrders[1:]))
 	var wg sync.WaitGroup
 	wg.Add(len(g.ResOrders[1:]))
-	for _, ord := range g.ResOrders[1:] {
-		go func(ordItem *ResOrder) {
+	for i, ord := range g.ResOrders[1:] {
+		go func(ordItem *ResOrder, rng *rand.Rand) {
 			defer wg.Done()
-			ordItem.mutateAll(g.Config.Inps)
+			ordItem.mutateAll(rng, g.Config.Inps)
 			/*for i := 0; i < ordItem.Count; i++ {
-				ordItem.mutateV3(g.Config.Inps)
+				ordItem.mutateV3(rng, g.Config.Inps)
 			}*/
-		}(ord)
+		}(ord, rngs[i])
 	}
 	wg.Wait()
 }
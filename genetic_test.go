@@ -0,0 +1,154 @@
+package neuro
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func testGenetic(seed int64, conf GeneticConf) *Genetic {
+	g := InitGenetic(conf).SetFitness(ErrorFitness)
+	g.SetSeed(seed)
+	for i := 0; i < conf.LastBest; i++ {
+		g.AddNet(InitNetPerc(1, 4).SetBias(true).SetSeed(int64(i+1)).SetWeight(-1, 1).CreateNet(xorData, 1))
+	}
+	return g
+}
+
+func testConf() GeneticConf {
+	return GeneticConf{
+		Population:     30,
+		LastBest:       6,
+		LimitMutateSub: 3,
+		MinRandWeight:  -1,
+		MaxRandWeight:  1,
+		CrossoverRate:  0.3,
+	}
+}
+
+func sameBest(t *testing.T, what string, a, b *Genetic) {
+	t.Helper()
+	if a.GetBest().Score != b.GetBest().Score || a.Score != b.Score {
+		t.Fatalf("%s: best scores %g and %g", what, a.GetBest().Score, b.GetBest().Score)
+	}
+	for il, l := range a.GetBest().Dense {
+		for k, w := range l.Weights {
+			if w != b.GetBest().Dense[il].Weights[k] {
+				t.Fatalf("%s: best nets differ in layer %d", what, il)
+			}
+		}
+	}
+}
+
+// TestGeneticSeed runs under go test -race: the nets train in parallel, yet
+// the same seed has to give the same generations.
+func TestGeneticSeed(t *testing.T) {
+	run := func(seed int64) *Genetic {
+		g := testGenetic(seed, testConf())
+		for i := 0; i < 15; i++ {
+			g.Train(false)
+		}
+		return g
+	}
+	a, b := run(42), run(42)
+	sameBest(t, "same seed", a, b)
+
+	c := run(43)
+	differ := false
+	for il, l := range a.GetBest().Dense {
+		for k, w := range l.Weights {
+			differ = differ || w != c.GetBest().Dense[il].Weights[k]
+		}
+	}
+	if !differ {
+		t.Fatal("another seed gave the same best net")
+	}
+}
+
+func TestGeneticResume(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "gen.json")
+	conf := testConf()
+	conf.CheckpointEvery = 5
+	conf.CheckpointFile = file
+	straight := testGenetic(7, conf)
+	for i := 0; i < 15; i++ {
+		straight.Train(false)
+	}
+
+	first := testGenetic(7, conf)
+	for i := 0; i < 10; i++ {
+		first.Train(false)
+	}
+	resumed := InitGenetic().SetFitness(ErrorFitness)
+	if err := resumed.Resume(file, xorData); err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Iters != 10 {
+		t.Fatalf("resumed at generation %d, want 10", resumed.Iters)
+	}
+	for i := 0; i < 5; i++ {
+		resumed.Train(false)
+	}
+	sameBest(t, "resumed", straight, resumed)
+}
+
+func BenchmarkGeneticTrain(b *testing.B) {
+	data := benchSet(64, 10, 3)
+	g := InitGenetic(GeneticConf{
+		Population:     50,
+		LastBest:       10,
+		LimitMutateSub: 100,
+		MinRandWeight:  -1,
+		MaxRandWeight:  1,
+		Hours:          24,
+		TradesByDay:    1,
+	})
+	for i := 0; i < 50; i++ {
+		g.AddNet(InitNetPerc(2, 120).SetBias(true).SetWeight(-1, 1).CreateNet(data, 1))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.Train(false)
+	}
+}
+
+func TestRunCtx(t *testing.T) {
+	if _, err := InitGenetic(testConf()).RunCtx(context.Background()); err == nil {
+		t.Fatal("no error without nets")
+	}
+
+	g := InitGenetic(testConf())
+	for i := 0; i < 3; i++ {
+		g.AddNet(InitNetPerc(1, 4).CreateNet(xorData, 1))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	best, err := g.RunCtx(ctx)
+	if err != context.Canceled {
+		t.Fatalf("cancelled run returned %v", err)
+	}
+	if best != g.Nets[0] || g.Iters != 0 {
+		t.Fatalf("cancelled run trained %d generations", g.Iters)
+	}
+}
+
+func TestFirstMutateCtx(t *testing.T) {
+	data := make([]DataTeach, 4)
+	for i := range data {
+		data[i].Price = float64(10 - i*i)
+	}
+	g := InitGenetic(GeneticConf{Budget: 100})
+	g.ResOrders = []*ResOrder{{Trades: []int{0, 1}}, {Trades: []int{1, 3}}, {Trades: []int{2, 3}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := g.FirstMutateCtx(ctx, data); err != context.Canceled {
+		t.Fatalf("cancelled search returned %v", err)
+	}
+	if g.Iters != 0 {
+		t.Fatalf("cancelled search ran %d generations", g.Iters)
+	}
+	// buying at 10 and selling at 9 loses least
+	if lbo := g.LBOitem; lbo.Score != 99 || len(lbo.Trades) != 2 || lbo.Trades[0] != 0 || lbo.Trades[1] != 1 {
+		t.Fatalf("best orders %+v, want trades [0 1] with 99", *lbo)
+	}
+}
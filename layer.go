@@ -0,0 +1,250 @@
+package neuro
+
+// Layer is a dense connection between two neighbour layers of the net.
+// Weights holds In rows of Out weights, so the weight from input i to
+// neuron j is Weights[i*Out+j]. A row is what the Perc of input i keeps as
+// its Weights, which lets NetPerc.Net share the memory of the layers.
+type Layer struct {
+	In      int         `json:"in"`
+	Out     int         `json:"out"`
+	Bias    bool        `json:"bias"`
+	Weights []float64   `json:"weights"`
+	Biases  []float64   `json:"biases"`
+	OptW    [][]float64 `json:"opt_w,omitempty"`
+	OptB    [][]float64 `json:"opt_b,omitempty"`
+
+	sum   []float64
+	out   []float64
+	delta []float64
+	gradW []float64
+	gradB []float64
+}
+
+func newLayer(in, out int, bias bool) *Layer {
+	l := &Layer{
+		In:      in,
+		Out:     out,
+		Bias:    bias,
+		Weights: make([]float64, in*out),
+		Biases:  make([]float64, out),
+	}
+	l.alloc()
+	return l
+}
+
+func (l *Layer) alloc() {
+	if len(l.out) == l.Out {
+		return
+	}
+	l.sum = make([]float64, l.Out)
+	l.out = make([]float64, l.Out)
+	l.delta = make([]float64, l.Out)
+	l.gradW = make([]float64, l.In*l.Out)
+	l.gradB = make([]float64, l.Out)
+	if len(l.Biases) != l.Out {
+		l.Biases = make([]float64, l.Out)
+	}
+}
+
+func (l *Layer) forward(x []float64, act Activation) []float64 {
+	copy(l.sum, l.Biases)
+	for i, xi := range x[:l.In] {
+		row := l.Weights[i*l.Out : (i+1)*l.Out]
+		for j, w := range row {
+			l.sum[j] += w * xi
+		}
+	}
+	if _, ok := act.(Softmax); ok {
+		softmax(l.sum, l.out)
+		return l.out
+	}
+	for j, s := range l.sum {
+		l.out[j] = act.Func(s)
+	}
+	return l.out
+}
+
+// backward spreads the deltas of l to the previous layer prev.
+func (l *Layer) backward(prev *Layer, act Activation) {
+	for i := range prev.delta {
+		var s float64
+		row := l.Weights[i*l.Out : (i+1)*l.Out]
+		for j, w := range row {
+			s += w * l.delta[j]
+		}
+		prev.delta[i] = s * act.Deriv(prev.sum[i], prev.out[i])
+	}
+}
+
+// accumulate adds the gradient of the current sample, kept as the error
+// direction delta*x, to the batch sums.
+func (l *Layer) accumulate(x []float64) {
+	for i, xi := range x[:l.In] {
+		row := l.gradW[i*l.Out : (i+1)*l.Out]
+		for j, d := range l.delta {
+			row[j] += d * xi
+		}
+	}
+	for j, d := range l.delta {
+		l.gradB[j] += d
+	}
+}
+
+// apply averages the accumulated sums of count samples into gradients and
+// hands them to the optimizer.
+func (l *Layer) apply(opt Optimizer, rate float64, count, step int) {
+	scale := -1 / float64(count)
+	for k := range l.gradW {
+		l.gradW[k] *= scale
+	}
+	l.OptW = optSlots(l.OptW, opt.Slots(), len(l.Weights))
+	opt.Update(l.Weights, l.gradW, l.OptW, rate, step)
+	if l.Bias {
+		for j := range l.gradB {
+			l.gradB[j] *= scale
+		}
+		l.OptB = optSlots(l.OptB, opt.Slots(), len(l.Biases))
+		opt.Update(l.Biases, l.gradB, l.OptB, rate, step)
+	}
+	l.clearGrads()
+}
+
+func (l *Layer) clearGrads() {
+	for k := range l.gradW {
+		l.gradW[k] = 0
+	}
+	for j := range l.gradB {
+		l.gradB[j] = 0
+	}
+}
+
+// prepare makes the net ready for a pass: dumps that still hold the old
+// [][]*Perc layout are converted, the layer buffers are allocated and Net is
+// pointed at the layers.
+func (n *NetPerc) prepare() {
+	if len(n.Dense) == 0 && len(n.Net) > 1 {
+		n.Dense = percsToLayers(n.Net)
+	}
+	for _, l := range n.Dense {
+		l.alloc()
+	}
+	if !n.linked() {
+		n.linkNet()
+	}
+}
+
+// linkNet builds n.Net as a view of n.Dense in the old layout: one Perc per
+// neuron and a bias Perc after the neurons of layers with a bias. The
+// weights of the percs are the rows of the layers, so changing them changes
+// the net; Value, PreVals and Error are not kept up to date.
+func (n *NetPerc) linkNet() {
+	if len(n.Dense) == 0 {
+		n.Net = nil
+		return
+	}
+	n.Net = make([][]*Perc, len(n.Dense)+1)
+	for il, l := range n.Dense {
+		percs := make([]*Perc, l.In, l.In+1)
+		for i := range percs {
+			percs[i] = &Perc{
+				Start:   il == 0,
+				Weights: l.Weights[i*l.Out : (i+1)*l.Out : (i+1)*l.Out],
+			}
+		}
+		if l.Bias {
+			bias := n.AddBias()
+			bias.Weights = l.Biases
+			percs = append(percs, bias)
+		}
+		n.Net[il] = percs
+	}
+	last := n.Dense[len(n.Dense)-1]
+	outs := make([]*Perc, last.Out)
+	for j := range outs {
+		outs[j] = &Perc{Final: true}
+	}
+	n.Net[len(n.Dense)] = outs
+}
+
+// linked reports whether n.Net still shares the weights of n.Dense.
+func (n *NetPerc) linked() bool {
+	if len(n.Net) != len(n.Dense)+1 {
+		return false
+	}
+	for il, l := range n.Dense {
+		percs := n.Net[il]
+		size := l.In
+		if l.Bias {
+			size++
+		}
+		if len(percs) != size || !shares(percs[0], l.Weights) || l.Bias && !shares(percs[l.In], l.Biases) {
+			return false
+		}
+	}
+	return len(n.Net[len(n.Dense)]) == n.Dense[len(n.Dense)-1].Out
+}
+
+func shares(p *Perc, fls []float64) bool {
+	return p != nil && len(p.Weights) > 0 && len(fls) > 0 && &p.Weights[0] == &fls[0]
+}
+
+func percsToLayers(net [][]*Perc) []*Layer {
+	var layers []*Layer
+	for il := 0; il < len(net)-1; il++ {
+		var (
+			inps []*Perc
+			bias *Perc
+		)
+		for _, p := range net[il] {
+			if p.Bias {
+				bias = p
+			} else {
+				inps = append(inps, p)
+			}
+		}
+		l := newLayer(len(inps), countPercs(net[il+1]), bias != nil)
+		for i, p := range inps {
+			copy(l.Weights[i*l.Out:(i+1)*l.Out], p.Weights)
+		}
+		if bias != nil {
+			copy(l.Biases, bias.Weights)
+		}
+		layers = append(layers, l)
+	}
+	return layers
+}
+
+func countPercs(layer []*Perc) int {
+	var count int
+	for _, p := range layer {
+		if !p.Bias {
+			count++
+		}
+	}
+	return count
+}
+
+func (l *Layer) copy() *Layer {
+	nl := &Layer{
+		In:      l.In,
+		Out:     l.Out,
+		Bias:    l.Bias,
+		Weights: copyFloats(l.Weights),
+		Biases:  copyFloats(l.Biases),
+	}
+	for _, s := range l.OptW {
+		nl.OptW = append(nl.OptW, copyFloats(s))
+	}
+	for _, s := range l.OptB {
+		nl.OptB = append(nl.OptB, copyFloats(s))
+	}
+	nl.alloc()
+	return nl
+}
+
+func copyFloats(fls []float64) []float64 {
+	if fls == nil {
+		return nil
+	}
+	return append(make([]float64, 0, len(fls)), fls...)
+}
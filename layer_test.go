@@ -0,0 +1,81 @@
+package neuro
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestLayerGradients compares the gradients backpropagation leaves in every
+// layer with central differences of the loss.
+func TestLayerGradients(t *testing.T) {
+	d := DataTeach{Inputs: []float64{0.3, -0.7, 0.5}, Outputs: []float64{0, 1, 0}}
+	cases := []struct {
+		loss Loss
+		out  Activation
+	}{
+		{MSE{}, Sigmoid{}},
+		{BCE{}, Sigmoid{}},
+		{CCE{}, Softmax{}},
+		{Huber{Delta: 0.3}, Tanh{}},
+		{MAE{}, Identity{}},
+	}
+	for _, c := range cases {
+		n := InitNetPerc(2, 4).SetBias(true).SetSeed(1).SetWeight(-1, 1).SetLoss(c.loss)
+		n.CreateNet([]DataTeach{d}, 1, Tanh{}, Tanh{}, c.out)
+		n.CurrInd = 0
+		n.forwardPass()
+		n.calcError()
+		n.backPropogation()
+		lossOf := func() float64 {
+			return c.loss.Value(n.forward(d.Inputs), d.Outputs)
+		}
+		check := func(il int, what string, fls, grads []float64) {
+			const eps = 1e-6
+			for k, v := range fls {
+				fls[k] = v + eps
+				up := lossOf()
+				fls[k] = v - eps
+				down := lossOf()
+				fls[k] = v
+				num := (up - down) / (2 * eps)
+				// the gradients are kept as the descent direction
+				got := -grads[k]
+				if diff := math.Abs(num-got) / math.Max(1, math.Abs(num)+math.Abs(got)); diff > 1e-6 {
+					t.Errorf("%s/%s layer %d %s[%d]: backprop %g, numeric %g", c.loss.Name(), c.out.Name(), il, what, k, got, num)
+				}
+			}
+		}
+		for il, l := range n.Dense {
+			if !l.Bias {
+				t.Fatalf("layer %d has no bias", il)
+			}
+			check(il, "weights", l.Weights, l.gradW)
+			check(il, "biases", l.Biases, l.gradB)
+		}
+	}
+}
+
+// benchSet is a data set of the size the trading nets work with.
+func benchSet(rows, inps, outs int) []DataTeach {
+	rng := rand.New(rand.NewSource(1))
+	data := make([]DataTeach, rows)
+	for i := range data {
+		data[i].Inputs = make([]float64, inps)
+		data[i].Outputs = make([]float64, outs)
+		for j := range data[i].Inputs {
+			data[i].Inputs[j] = rng.Float64()
+		}
+		data[i].Outputs[rng.Intn(outs)] = 1
+	}
+	return data
+}
+
+func BenchmarkForward(b *testing.B) {
+	n := InitNetPerc(2, 120).SetBias(true).CreateNet(benchSet(64, 10, 3), 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n.CurrInd = i % len(n.Data)
+		n.forwardPass()
+	}
+}
@@ -0,0 +1,264 @@
+package neuro
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Loss measures one sample. Value sums over the outputs, Train then averages
+// over the data set. Grad writes dLoss/dOut for every output into grad.
+type Loss interface {
+	Name() string
+	Value(out, target []float64) float64
+	Grad(out, target, grad []float64)
+}
+
+const lossEps = 1e-12
+
+type MSE struct{}
+
+func (MSE) Name() string { return "mse" }
+
+func (MSE) Value(out, target []float64) float64 {
+	var sum float64
+	for i, t := range target {
+		sum += (out[i] - t) * (out[i] - t)
+	}
+	return sum
+}
+
+func (MSE) Grad(out, target, grad []float64) {
+	for i, t := range target {
+		grad[i] = 2 * (out[i] - t)
+	}
+}
+
+type MAE struct{}
+
+func (MAE) Name() string { return "mae" }
+
+func (MAE) Value(out, target []float64) float64 {
+	var sum float64
+	for i, t := range target {
+		sum += math.Abs(out[i] - t)
+	}
+	return sum
+}
+
+func (MAE) Grad(out, target, grad []float64) {
+	for i, t := range target {
+		switch {
+		case out[i] > t:
+			grad[i] = 1
+		case out[i] < t:
+			grad[i] = -1
+		default:
+			grad[i] = 0
+		}
+	}
+}
+
+type Huber struct {
+	Delta float64
+}
+
+func (h Huber) Name() string { return "huber:" + strconv.FormatFloat(h.Delta, 'g', -1, 64) }
+
+func (h Huber) Value(out, target []float64) float64 {
+	delta := orDefault(h.Delta, 1)
+	var sum float64
+	for i, t := range target {
+		d := math.Abs(out[i] - t)
+		if d <= delta {
+			sum += 0.5 * d * d
+		} else {
+			sum += delta * (d - 0.5*delta)
+		}
+	}
+	return sum
+}
+
+func (h Huber) Grad(out, target, grad []float64) {
+	delta := orDefault(h.Delta, 1)
+	for i, t := range target {
+		grad[i] = math.Max(-delta, math.Min(delta, out[i]-t))
+	}
+}
+
+// BCE is binary cross-entropy for independent outputs in (0, 1). With a
+// sigmoid output layer the output delta is simply target - out.
+type BCE struct{}
+
+func (BCE) Name() string { return "bce" }
+
+func (BCE) Value(out, target []float64) float64 {
+	var sum float64
+	for i, t := range target {
+		y := clampProb(out[i])
+		sum -= t*math.Log(y) + (1-t)*math.Log(1-y)
+	}
+	return sum
+}
+
+func (BCE) Grad(out, target, grad []float64) {
+	for i, t := range target {
+		y := clampProb(out[i])
+		grad[i] = (y - t) / (y * (1 - y))
+	}
+}
+
+// CCE is categorical cross-entropy over one-hot targets, meant for a
+// softmax output layer where the output delta becomes target - out.
+type CCE struct{}
+
+func (CCE) Name() string { return "cce" }
+
+func (CCE) Value(out, target []float64) float64 {
+	var sum float64
+	for i, t := range target {
+		if t != 0 {
+			sum -= t * math.Log(clampProb(out[i]))
+		}
+	}
+	return sum
+}
+
+func (CCE) Grad(out, target, grad []float64) {
+	for i, t := range target {
+		grad[i] = -t / clampProb(out[i])
+	}
+}
+
+func clampProb(y float64) float64 {
+	return math.Min(math.Max(y, lossEps), 1-lossEps)
+}
+
+var (
+	lossMtx      sync.RWMutex
+	customLosses = map[string]Loss{}
+)
+
+// RegisterLoss makes a custom loss known to LossByName under its Name(), like
+// RegisterActivation. It panics on the name of a built-in loss.
+func RegisterLoss(loss Loss) {
+	name := loss.Name()
+	if builtin, _ := builtinLoss(name); builtin != nil || name == "" {
+		panic(fmt.Sprintf("neuro: loss name %q is taken", name))
+	}
+	lossMtx.Lock()
+	customLosses[name] = loss
+	lossMtx.Unlock()
+}
+
+func LossByName(name string) (Loss, error) {
+	loss, err := builtinLoss(name)
+	if loss != nil || err != nil {
+		return loss, err
+	}
+	lossMtx.RLock()
+	loss, ok := customLosses[name]
+	lossMtx.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown loss %q", name)
+	}
+	return loss, nil
+}
+
+// builtinLoss returns nil and no error for names it does not know.
+func builtinLoss(name string) (Loss, error) {
+	base, param := name, ""
+	if i := strings.Index(name, ":"); i >= 0 {
+		base, param = name[:i], name[i+1:]
+	}
+	switch base {
+	case "mse":
+		return MSE{}, nil
+	case "mae":
+		return MAE{}, nil
+	case "huber":
+		h := Huber{Delta: 1}
+		if param != "" {
+			delta, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				return nil, err
+			}
+			h.Delta = delta
+		}
+		return h, nil
+	case "bce":
+		return BCE{}, nil
+	case "cce":
+		return CCE{}, nil
+	}
+	return nil, nil
+}
+
+// SetLoss picks the training loss. Without one the net keeps the original
+// sum of squared errors with the raw target - out output delta.
+func (n *NetPerc) SetLoss(loss Loss) *NetPerc {
+	n.LossName = ""
+	if loss != nil {
+		n.LossName = loss.Name()
+	}
+	n.loss = loss
+	return n
+}
+
+func (n *NetPerc) getLoss() Loss {
+	if n.loss == nil && n.LossName != "" {
+		n.loss, _ = LossByName(n.LossName)
+	}
+	return n.loss
+}
+
+// outputError fills the output layer deltas for target and returns the loss
+// of the current sample.
+func (n *NetPerc) outputError(target []float64) float64 {
+	out := n.Dense[len(n.Dense)-1]
+	loss := n.getLoss()
+	if loss == nil {
+		var allErr float64
+		for i, o := range target {
+			out.delta[i] = o - out.out[i]
+			allErr += math.Pow(out.delta[i], 2)
+		}
+		return allErr
+	}
+	act := n.layerAct(len(n.Dense) - 1)
+	if fusedLoss(loss, act) {
+		for i, o := range target {
+			out.delta[i] = o - out.out[i]
+		}
+		return loss.Value(out.out, target)
+	}
+	loss.Grad(out.out, target, out.delta)
+	if _, ok := act.(Softmax); ok {
+		var dot float64
+		for i, g := range out.delta[:len(target)] {
+			dot += g * out.out[i]
+		}
+		for i := range target {
+			out.delta[i] = -out.out[i] * (out.delta[i] - dot)
+		}
+	} else {
+		for i := range target {
+			out.delta[i] = -out.delta[i] * act.Deriv(out.sum[i], out.out[i])
+		}
+	}
+	return loss.Value(out.out, target)
+}
+
+func fusedLoss(loss Loss, act Activation) bool {
+	switch loss.(type) {
+	case BCE:
+		_, ok := act.(Sigmoid)
+		return ok
+	case CCE:
+		_, ok := act.(Softmax)
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package neuro
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+type quarticLoss struct{}
+
+func (quarticLoss) Name() string { return "quartic" }
+
+func (quarticLoss) Value(out, target []float64) float64 {
+	var sum float64
+	for i, t := range target {
+		d := out[i] - t
+		sum += d * d * d * d
+	}
+	return sum
+}
+
+func (quarticLoss) Grad(out, target, grad []float64) {
+	for i, t := range target {
+		d := out[i] - t
+		grad[i] = 4 * d * d * d
+	}
+}
+
+func TestCopyKeepsLoss(t *testing.T) {
+	n := InitNetPerc(1, 3).SetLoss(quarticLoss{}).CreateNet(xorData, 1)
+	c := n.Copy()
+	if _, ok := c.getLoss().(quarticLoss); !ok {
+		t.Fatalf("copy uses %v, want the custom loss", c.getLoss())
+	}
+	n.forward(xorData[1].Inputs)
+	c.forward(xorData[1].Inputs)
+	if a, b := n.outputError(xorData[1].Outputs), c.outputError(xorData[1].Outputs); a != b {
+		t.Fatalf("copy error %g, original %g", b, a)
+	}
+}
+
+func TestSetSoftmaxAfterCreate(t *testing.T) {
+	data := []DataTeach{{Inputs: []float64{0, 1}, Outputs: []float64{0, 1, 0}}}
+	n := InitNetPerc(1, 3).CreateNet(data, 1)
+	n.SetSoftmax(true)
+	if _, ok := n.layerAct(1).(Softmax); !ok || n.Activations[1] != "softmax" {
+		t.Fatalf("output is %s after SetSoftmax(true)", n.layerAct(1).Name())
+	}
+	var sum float64
+	for _, p := range n.forward(data[0].Inputs) {
+		sum += p
+	}
+	if sum < 1-1e-9 || sum > 1+1e-9 {
+		t.Fatalf("softmax outputs sum to %g", sum)
+	}
+	n.SetSoftmax(false)
+	if _, ok := n.layerAct(1).(Sigmoid); !ok {
+		t.Fatalf("output is %s after SetSoftmax(false)", n.layerAct(1).Name())
+	}
+}
+
+type cubicLoss struct{ quarticLoss }
+
+func (cubicLoss) Name() string { return "cubic" }
+
+func TestSaveCustomLoss(t *testing.T) {
+	dir := t.TempDir()
+	unknown := InitNetPerc(1, 3).SetLoss(cubicLoss{}).CreateNet(xorData, 1)
+	if err := unknown.Save(filepath.Join(dir, "cubic.json")); err == nil {
+		t.Fatal("saved a net with an unregistered loss")
+	}
+
+	RegisterLoss(quarticLoss{})
+	file := filepath.Join(dir, "quartic.json")
+	if err := InitNetPerc(1, 3).SetLoss(quarticLoss{}).CreateNet(xorData, 1).Save(file); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadNet(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.getLoss().(quarticLoss); !ok {
+		t.Fatalf("loaded net uses %v", loaded.getLoss())
+	}
+}
@@ -0,0 +1,227 @@
+// Package metrics scores predictions of a net against the expected outputs.
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const probEps = 1e-15
+
+// ConfusionMatrix counts samples by expected class (row) and predicted
+// class (column).
+type ConfusionMatrix [][]int
+
+type ClassStats struct {
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+	Support   int     `json:"support"`
+}
+
+// Classification is the report of Classify. AUC is left 0 where it is not
+// defined: more than two classes or only one class present.
+type Classification struct {
+	Classes  int             `json:"classes"`
+	Total    int             `json:"total"`
+	Correct  int             `json:"correct"`
+	Accuracy float64         `json:"accuracy"`
+	Matrix   ConfusionMatrix `json:"matrix"`
+	PerClass []ClassStats    `json:"per_class"`
+	Macro    ClassStats      `json:"macro"`
+	Weighted ClassStats      `json:"weighted"`
+	LogLoss  float64         `json:"log_loss"`
+	AUC      float64         `json:"auc"`
+}
+
+// Classify compares raw outputs with one-hot targets. A single output
+// column is a binary problem with the class boundary at 0.5, wider rows
+// pick the class by argmax. Outputs and targets of different shapes are an
+// error.
+func Classify(outputs, targets [][]float64) (Classification, error) {
+	var c Classification
+	if err := checkShape(outputs, targets); err != nil {
+		return c, err
+	}
+	binary := len(outputs[0]) == 1
+	c.Classes = len(outputs[0])
+	if binary {
+		c.Classes = 2
+	}
+	c.Matrix = make(ConfusionMatrix, c.Classes)
+	for i := range c.Matrix {
+		c.Matrix[i] = make([]int, c.Classes)
+	}
+	var (
+		scores []float64
+		labels []bool
+	)
+	for i, out := range outputs {
+		var want, got int
+		if binary {
+			want, got = boolClass(targets[i][0]), boolClass(out[0])
+			c.LogLoss -= math.Log(prob(out[0], want == 1))
+		} else {
+			want, got = argmax(targets[i]), argmax(out)
+			c.LogLoss -= math.Log(classProb(out, want))
+		}
+		c.Matrix[want][got]++
+		c.Total++
+		if want == got {
+			c.Correct++
+		}
+		if c.Classes == 2 {
+			score := out[0]
+			if !binary {
+				score = out[1]
+			}
+			scores = append(scores, score)
+			labels = append(labels, want == 1)
+		}
+	}
+	c.Accuracy = float64(c.Correct) / float64(c.Total)
+	c.LogLoss /= float64(c.Total)
+	c.PerClass = c.Matrix.Stats()
+	c.Macro, c.Weighted = averages(c.PerClass, c.Total)
+	if auc := AUC(scores, labels); c.Classes == 2 && !math.IsNaN(auc) {
+		c.AUC = auc
+	}
+	return c, nil
+}
+
+// checkShape makes sure there are samples and that every row of outputs
+// and targets is as wide as the first output row.
+func checkShape(outputs, targets [][]float64) error {
+	if len(outputs) != len(targets) {
+		return fmt.Errorf("%d outputs for %d targets", len(outputs), len(targets))
+	}
+	if len(outputs) == 0 {
+		return errors.New("no samples")
+	}
+	width := len(outputs[0])
+	if width == 0 {
+		return errors.New("row 0: no outputs")
+	}
+	for i, out := range outputs {
+		if len(out) != width || len(targets[i]) != width {
+			return fmt.Errorf("row %d: %d outputs and %d targets, want %d", i, len(out), len(targets[i]), width)
+		}
+	}
+	return nil
+}
+
+func (m ConfusionMatrix) Stats() []ClassStats {
+	stats := make([]ClassStats, len(m))
+	for k := range m {
+		var tp, fp, fn int
+		tp = m[k][k]
+		for j := range m {
+			if j != k {
+				fn += m[k][j]
+				fp += m[j][k]
+			}
+		}
+		s := ClassStats{Support: tp + fn}
+		s.Precision = ratio(tp, tp+fp)
+		s.Recall = ratio(tp, tp+fn)
+		if s.Precision+s.Recall > 0 {
+			s.F1 = 2 * s.Precision * s.Recall / (s.Precision + s.Recall)
+		}
+		stats[k] = s
+	}
+	return stats
+}
+
+func averages(stats []ClassStats, total int) (macro, weighted ClassStats) {
+	for _, s := range stats {
+		macro.Precision += s.Precision / float64(len(stats))
+		macro.Recall += s.Recall / float64(len(stats))
+		macro.F1 += s.F1 / float64(len(stats))
+		w := float64(s.Support) / float64(total)
+		weighted.Precision += s.Precision * w
+		weighted.Recall += s.Recall * w
+		weighted.F1 += s.F1 * w
+	}
+	macro.Support, weighted.Support = total, total
+	return macro, weighted
+}
+
+// AUC is the area under the ROC curve of scores for positive labels, with
+// ties counted as half. It is NaN when one of the classes is missing.
+func AUC(scores []float64, labels []bool) float64 {
+	idx := make([]int, len(scores))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool { return scores[idx[a]] < scores[idx[b]] })
+	var pos, neg int
+	var rankSum float64
+	for i := 0; i < len(idx); {
+		j := i
+		for j < len(idx) && scores[idx[j]] == scores[idx[i]] {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if labels[idx[k]] {
+				pos++
+				rankSum += rank
+			} else {
+				neg++
+			}
+		}
+		i = j
+	}
+	if pos == 0 || neg == 0 {
+		return math.NaN()
+	}
+	return (rankSum - float64(pos*(pos+1))/2) / float64(pos*neg)
+}
+
+func boolClass(v float64) int {
+	if v >= 0.5 {
+		return 1
+	}
+	return 0
+}
+
+func prob(p float64, positive bool) float64 {
+	p = math.Min(math.Max(p, probEps), 1-probEps)
+	if positive {
+		return p
+	}
+	return 1 - p
+}
+
+// classProb is the probability given to class k, outputs that are not a
+// distribution yet are normalised by their sum.
+func classProb(out []float64, k int) float64 {
+	var sum float64
+	for _, v := range out {
+		sum += math.Max(v, 0)
+	}
+	p := math.Max(out[k], 0)
+	if sum > 0 {
+		p /= sum
+	}
+	return math.Min(math.Max(p, probEps), 1)
+}
+
+func argmax(fls []float64) int {
+	var maxI int
+	for i, fl := range fls {
+		if fl > fls[maxI] {
+			maxI = i
+		}
+	}
+	return maxI
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+var shapeCases = map[string][2][][]float64{
+	"no samples":    {nil, nil},
+	"fewer targets": {{{1}, {2}}, {{1}}},
+	"more targets":  {{{1}}, {{1}, {2}}},
+	"short row":     {{{1, 0}, {0}}, {{1, 0}, {0, 1}}},
+	"wide row":      {{{1, 0}, {0, 1, 0}}, {{1, 0}, {0, 1}}},
+	"wide target":   {{{1}, {0}}, {{1}, {0, 1}}},
+	"empty rows":    {{{}}, {{}}},
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-12
+}
+
+func TestClassify(t *testing.T) {
+	targets := [][]float64{{1, 0, 0}, {1, 0, 0}, {1, 0, 0}, {0, 1, 0}, {0, 1, 0}, {0, 0, 1}}
+	outputs := [][]float64{
+		{0.7, 0.2, 0.1},
+		{0.6, 0.3, 0.1},
+		{0.3, 0.5, 0.2},
+		{0.1, 0.8, 0.1},
+		{0.1, 0.4, 0.5},
+		{0.2, 0.2, 0.6},
+	}
+	c, err := Classify(outputs, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matrix := ConfusionMatrix{{2, 1, 0}, {0, 1, 1}, {0, 0, 1}}
+	for i := range matrix {
+		for j := range matrix[i] {
+			if c.Matrix[i][j] != matrix[i][j] {
+				t.Fatalf("matrix %v, want %v", c.Matrix, matrix)
+			}
+		}
+	}
+	if c.Classes != 3 || c.Total != 6 || c.Correct != 4 || !near(c.Accuracy, 4.0/6) {
+		t.Fatalf("%d classes, %d of %d correct, accuracy %g", c.Classes, c.Correct, c.Total, c.Accuracy)
+	}
+	perClass := []ClassStats{
+		{Precision: 1, Recall: 2.0 / 3, F1: 0.8, Support: 3},
+		{Precision: 0.5, Recall: 0.5, F1: 0.5, Support: 2},
+		{Precision: 0.5, Recall: 1, F1: 2.0 / 3, Support: 1},
+	}
+	macro := ClassStats{Precision: 2.0 / 3, Recall: 13.0 / 18, F1: (0.8 + 0.5 + 2.0/3) / 3, Support: 6}
+	weighted := ClassStats{Precision: 0.75, Recall: 4.0 / 6, F1: (3*0.8 + 2*0.5 + 2.0/3) / 6, Support: 6}
+	same := func(what string, got, want ClassStats) {
+		if !near(got.Precision, want.Precision) || !near(got.Recall, want.Recall) || !near(got.F1, want.F1) || got.Support != want.Support {
+			t.Errorf("%s: %+v, want %+v", what, got, want)
+		}
+	}
+	for k := range perClass {
+		same(fmt.Sprintf("class %d", k), c.PerClass[k], perClass[k])
+	}
+	same("macro", c.Macro, macro)
+	same("weighted", c.Weighted, weighted)
+	logLoss := -(math.Log(0.7) + math.Log(0.6) + math.Log(0.3) + math.Log(0.8) + math.Log(0.4) + math.Log(0.6)) / 6
+	if !near(c.LogLoss, logLoss) {
+		t.Errorf("log loss %g, want %g", c.LogLoss, logLoss)
+	}
+	if c.AUC != 0 {
+		t.Errorf("AUC %g for three classes", c.AUC)
+	}
+}
+
+func TestClassifyBinary(t *testing.T) {
+	c, err := Classify([][]float64{{0.9}, {0.2}, {0.6}, {0.4}}, [][]float64{{1}, {0}, {0}, {1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Classes != 2 || c.Matrix[0][0] != 1 || c.Matrix[0][1] != 1 || c.Matrix[1][0] != 1 || c.Matrix[1][1] != 1 {
+		t.Fatalf("%d classes, matrix %v", c.Classes, c.Matrix)
+	}
+	logLoss := -(math.Log(0.9) + math.Log(0.8) + math.Log(0.4) + math.Log(0.4)) / 4
+	if !near(c.LogLoss, logLoss) {
+		t.Errorf("log loss %g, want %g", c.LogLoss, logLoss)
+	}
+	if !near(c.AUC, 0.75) {
+		t.Errorf("AUC %g, want 0.75", c.AUC)
+	}
+}
+
+func TestAUC(t *testing.T) {
+	if auc := AUC([]float64{0.1, 0.4, 0.4, 0.8}, []bool{false, true, false, true}); !near(auc, 0.875) {
+		t.Errorf("AUC with a tie %g, want 0.875", auc)
+	}
+	if auc := AUC([]float64{0.1, 0.4}, []bool{true, true}); !math.IsNaN(auc) {
+		t.Errorf("AUC of one class %g, want NaN", auc)
+	}
+}
+
+func TestClassifyShapeMismatch(t *testing.T) {
+	for name, c := range shapeCases {
+		if r, err := Classify(c[0], c[1]); err == nil {
+			t.Errorf("%s: got %+v, want an error", name, r)
+		}
+	}
+}
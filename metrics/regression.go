@@ -0,0 +1,80 @@
+package metrics
+
+import "math"
+
+// Regression is the report of Regress. All values are taken over every
+// output of every sample. MAPE skips zero targets, DirAccuracy compares the
+// signs of prediction and non-zero targets, such as a next percent change.
+type Regression struct {
+	Total       int     `json:"total"`
+	MSE         float64 `json:"mse"`
+	RMSE        float64 `json:"rmse"`
+	MAE         float64 `json:"mae"`
+	MAPE        float64 `json:"mape"`
+	R2          float64 `json:"r2"`
+	Tolerance   float64 `json:"tolerance"`
+	Hits        int     `json:"hits"`
+	HitRate     float64 `json:"hit_rate"`
+	DirAccuracy float64 `json:"dir_accuracy"`
+}
+
+// Regress compares outputs with targets, a prediction within tol of its
+// target counts as a hit. Outputs and targets of different shapes are an
+// error.
+func Regress(outputs, targets [][]float64, tol float64) (Regression, error) {
+	r := Regression{Tolerance: tol}
+	if err := checkShape(outputs, targets); err != nil {
+		return r, err
+	}
+	var (
+		mean              float64
+		sumSq, sumAbs     float64
+		sumPerc           float64
+		percCount         int
+		dirCount, dirHits int
+	)
+	for i, out := range outputs {
+		for k, t := range targets[i] {
+			mean += t
+			r.Total++
+			diff := out[k] - t
+			sumSq += diff * diff
+			sumAbs += math.Abs(diff)
+			if math.Abs(diff) <= tol {
+				r.Hits++
+			}
+			if t != 0 {
+				sumPerc += math.Abs(diff / t)
+				percCount++
+				dirCount++
+				if math.Signbit(out[k]) == math.Signbit(t) && out[k] != 0 {
+					dirHits++
+				}
+			}
+		}
+	}
+	mean /= float64(r.Total)
+	var sumTot float64
+	for _, tg := range targets {
+		for _, t := range tg {
+			sumTot += (t - mean) * (t - mean)
+		}
+	}
+	r.MSE = sumSq / float64(r.Total)
+	r.RMSE = math.Sqrt(r.MSE)
+	r.MAE = sumAbs / float64(r.Total)
+	r.MAPE = ratioF(sumPerc*100, percCount)
+	if sumTot > 0 {
+		r.R2 = 1 - sumSq/sumTot
+	}
+	r.HitRate = float64(r.Hits) / float64(r.Total)
+	r.DirAccuracy = ratioF(float64(dirHits), dirCount)
+	return r, nil
+}
+
+func ratioF(a float64, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / float64(b)
+}
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRegress(t *testing.T) {
+	r, err := Regress([][]float64{{1}, {2}, {-3}}, [][]float64{{1.5}, {2}, {3}}, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Total != 3 || r.Hits != 2 {
+		t.Fatalf("total %d hits %d, want 3 and 2", r.Total, r.Hits)
+	}
+	if want := (0.25 + 0 + 36) / 3; math.Abs(r.MSE-want) > 1e-12 {
+		t.Fatalf("mse %g, want %g", r.MSE, want)
+	}
+	if math.Abs(r.DirAccuracy-2.0/3) > 1e-12 {
+		t.Fatalf("direction accuracy %g, want 2/3", r.DirAccuracy)
+	}
+}
+
+func TestRegressShapeMismatch(t *testing.T) {
+	for name, c := range shapeCases {
+		if r, err := Regress(c[0], c[1], 0.1); err == nil {
+			t.Errorf("%s: got %+v, want an error", name, r)
+		}
+	}
+}
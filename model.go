@@ -0,0 +1,158 @@
+package neuro
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Model is a read-only snapshot of a NetPerc for inference. It shares no
+// memory with the net it was compiled from, and Predict keeps its scratch
+// buffers in a pool, so one Model can serve many goroutines at once.
+type Model struct {
+	layers []*Layer
+	acts   []Activation
+	norm   *Norm
+	inps   int
+	outs   int
+	width  int
+	pool   sync.Pool
+}
+
+func (n *NetPerc) Compile() *Model {
+	n.prepare()
+	m := &Model{inps: n.Inps, outs: n.Outs}
+	m.norm = n.Norm.copy()
+	for il, l := range n.Dense {
+		ml := &Layer{
+			In:      l.In,
+			Out:     l.Out,
+			Bias:    l.Bias,
+			Weights: append([]float64(nil), l.Weights...),
+			Biases:  append([]float64(nil), l.Biases...),
+		}
+		m.layers = append(m.layers, ml)
+		m.acts = append(m.acts, n.layerAct(il))
+		if l.Out > m.width {
+			m.width = l.Out
+		}
+	}
+	if len(m.layers) > 0 {
+		m.inps = m.layers[0].In
+		m.outs = m.layers[len(m.layers)-1].Out
+	}
+	m.pool.New = func() interface{} {
+		buf := make([]float64, 2*m.width+m.inps)
+		return &buf
+	}
+	return m
+}
+
+func (m *Model) Inputs() int {
+	return m.inps
+}
+
+func (m *Model) Outputs() int {
+	return m.outs
+}
+
+// Predict runs inputs through the model and writes the outputs to out,
+// which is allocated only when it is shorter than Outputs(). It panics when
+// inputs does not have Inputs() values.
+func (m *Model) Predict(inputs, out []float64) []float64 {
+	m.checkInputs(inputs)
+	if len(out) < m.outs {
+		out = make([]float64, m.outs)
+	}
+	out = out[:m.outs]
+	buf := m.pool.Get().(*[]float64)
+	cur, next := (*buf)[:m.width], (*buf)[m.width:]
+	x := m.norm.apply(inputs, (*buf)[2*m.width:])
+	for il, l := range m.layers {
+		dst := next[:l.Out]
+		if il == len(m.layers)-1 {
+			dst = out
+		}
+		copy(dst, l.Biases)
+		for i, xi := range x[:l.In] {
+			row := l.Weights[i*l.Out : (i+1)*l.Out]
+			for j, w := range row {
+				dst[j] += w * xi
+			}
+		}
+		if _, ok := m.acts[il].(Softmax); ok {
+			softmax(dst, dst)
+		} else {
+			for j, s := range dst {
+				dst[j] = m.acts[il].Func(s)
+			}
+		}
+		x = dst
+		cur, next = next, cur
+	}
+	m.pool.Put(buf)
+	return out
+}
+
+func (m *Model) PredictClass(inputs []float64) int {
+	buf := m.pool.Get().(*[]float64)
+	class := argmax(m.Predict(inputs, (*buf)[:m.outs]))
+	m.pool.Put(buf)
+	return class
+}
+
+// PredictBatch predicts every row of inputs on a pool of workers, one per
+// CPU unless a count is given. Row i of the result belongs to inputs[i]. Like
+// Predict it panics on a row of the wrong length, before any work starts.
+func (m *Model) PredictBatch(inputs [][]float64, workers ...int) [][]float64 {
+	for _, row := range inputs {
+		m.checkInputs(row)
+	}
+	count := runtime.NumCPU()
+	if len(workers) > 0 && workers[0] > 0 {
+		count = workers[0]
+	}
+	if count > len(inputs) {
+		count = len(inputs)
+	}
+	flat := make([]float64, len(inputs)*m.outs)
+	res := make([][]float64, len(inputs))
+	for i := range res {
+		res[i] = flat[i*m.outs : (i+1)*m.outs : (i+1)*m.outs]
+	}
+	jobs := make(chan int, count)
+	var wg sync.WaitGroup
+	wg.Add(count)
+	for w := 0; w < count; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				m.Predict(inputs[i], res[i])
+			}
+		}()
+	}
+	for i := range inputs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return res
+}
+
+func (m *Model) checkInputs(inputs []float64) {
+	if len(inputs) != m.inps {
+		panic(fmt.Sprintf("neuro: model takes %d inputs, got %d", m.inps, len(inputs)))
+	}
+}
+
+func (n *NetPerc) PredictBatch(inputs [][]float64, workers ...int) [][]float64 {
+	return n.Compile().PredictBatch(inputs, workers...)
+}
+
+func DataInputs(data []DataTeach) [][]float64 {
+	inputs := make([][]float64, len(data))
+	for i, dt := range data {
+		inputs[i] = dt.Inputs
+	}
+	return inputs
+}
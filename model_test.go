@@ -0,0 +1,90 @@
+package neuro
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestModelPredictConcurrent runs under go test -race: predictions from many
+// goroutines must not share buffers and must match the net.
+func TestModelPredictConcurrent(t *testing.T) {
+	data := benchSet(32, 10, 3)
+	n := InitNetPerc(2, 16).SetBias(true).SetSeed(1).SetWeight(-1, 1).CreateNet(data, 1)
+	n.SetNorm(FitNorm(data))
+	want := make([][]float64, len(data))
+	for i, dt := range data {
+		want[i] = append([]float64(nil), n.forward(dt.Inputs)...)
+	}
+	m := n.Compile()
+	var wg sync.WaitGroup
+	errs := make(chan string, 8)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			out := make([]float64, m.Outputs())
+			for r := 0; r < 50; r++ {
+				i := (w + r) % len(data)
+				m.Predict(data[i].Inputs, out)
+				for k, v := range out {
+					if v != want[i][k] {
+						errs <- "prediction differs from the net"
+						return
+					}
+				}
+				if c := m.PredictClass(data[i].Inputs); c != argmax(want[i]) {
+					errs <- "class differs from the net"
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestModelPredictInputLength(t *testing.T) {
+	m := InitNetPerc(1, 3).CreateNet(xorData, 1).Compile()
+	for _, inputs := range [][]float64{{1}, {1, 0, 1}, nil} {
+		func() {
+			defer func() {
+				msg, _ := recover().(string)
+				if !strings.Contains(msg, "model takes 2 inputs") {
+					t.Errorf("%d inputs: recovered %q", len(inputs), msg)
+				}
+			}()
+			m.Predict(inputs, nil)
+		}()
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("PredictBatch accepted a short row")
+			}
+		}()
+		m.PredictBatch([][]float64{{0, 1}, {1}})
+	}()
+}
+
+func TestPredictBatchOrder(t *testing.T) {
+	data := benchSet(100, 10, 3)
+	n := InitNetPerc(2, 16).SetBias(true).SetWeight(-1, 1).CreateNet(data, 1)
+	for _, workers := range []int{0, 1, 3, 7, 200} {
+		got := n.PredictBatch(DataInputs(data), workers)
+		if len(got) != len(data) {
+			t.Fatalf("%d workers: %d rows for %d inputs", workers, len(got), len(data))
+		}
+		for i, dt := range data {
+			want := n.PredictProba(dt.Inputs)
+			for k := range want {
+				if got[i][k] != want[k] {
+					t.Fatalf("%d workers: row %d is %v, want %v", workers, i, got[i], want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package neuro
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Mutation changes the weights of a child in place. scale multiplies the
+// step size, the 1/5th success rule of Iterate adapts it, see
+// GeneticConf.StepWindow.
+type Mutation interface {
+	Name() string
+	Mutate(rng *rand.Rand, n *NetPerc, scale float64)
+}
+
+// GaussianMutation adds normal noise with deviation Sigma, one by default,
+// to every weight and bias with probability Rate. Without a rate one gene
+// is changed on average.
+type GaussianMutation struct {
+	Sigma float64
+	Rate  float64
+}
+
+func (m GaussianMutation) Name() string {
+	return "gauss:" + formatParams(m.Sigma, m.Rate)
+}
+
+func (m GaussianMutation) Mutate(rng *rand.Rand, n *NetPerc, scale float64) {
+	perturb(rng, n, m.Rate, orDefault(m.Sigma, 1)*scale)
+}
+
+// SelfAdaptiveMutation is the self-adaptation of evolution strategies: every
+// net carries its own Sigma, which is mutated log-normally with the learning
+// rate Tau before the net is perturbed with it like GaussianMutation. Nets
+// without one start at Sigma, Tau defaults to 1/sqrt(genes).
+type SelfAdaptiveMutation struct {
+	Sigma float64
+	Rate  float64
+	Tau   float64
+}
+
+func (m SelfAdaptiveMutation) Name() string {
+	return "adaptive:" + formatParams(m.Sigma, m.Rate, m.Tau)
+}
+
+func (m SelfAdaptiveMutation) Mutate(rng *rand.Rand, n *NetPerc, scale float64) {
+	n.prepare()
+	tau := m.Tau
+	if tau <= 0 {
+		tau = 1 / math.Sqrt(float64(genes(n)))
+	}
+	sigma := n.Sigma
+	if sigma <= 0 {
+		sigma = orDefault(m.Sigma, 1)
+	}
+	n.Sigma = sigma * math.Exp(tau*rng.NormFloat64())
+	perturb(rng, n, m.Rate, n.Sigma*scale)
+}
+
+func genes(n *NetPerc) int {
+	var count int
+	for _, l := range n.Dense {
+		count += len(l.Weights)
+		if l.Bias {
+			count += len(l.Biases)
+		}
+	}
+	return count
+}
+
+// perturb adds normal noise with deviation sigma to the genes of n with
+// probability rate, 1/genes without one.
+func perturb(rng *rand.Rand, n *NetPerc, rate, sigma float64) {
+	n.prepare()
+	if rate <= 0 {
+		rate = 1 / float64(genes(n))
+	}
+	add := func(fls []float64) {
+		for i := range fls {
+			if rng.Float64() < rate {
+				fls[i] += sigma * rng.NormFloat64()
+			}
+		}
+	}
+	for _, l := range n.Dense {
+		add(l.Weights)
+		if l.Bias {
+			add(l.Biases)
+		}
+	}
+}
+
+func formatParams(params ...float64) string {
+	strs := make([]string, len(params))
+	for i, p := range params {
+		strs[i] = strconv.FormatFloat(p, 'g', -1, 64)
+	}
+	return strings.Join(strs, ":")
+}
+
+func parseParams(param string, dst ...*float64) error {
+	if param == "" {
+		return nil
+	}
+	strs := strings.Split(param, ":")
+	if len(strs) > len(dst) {
+		return fmt.Errorf("too many parameters in %q", param)
+	}
+	for i, s := range strs {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		*dst[i] = v
+	}
+	return nil
+}
+
+// MutationByName returns nil for the empty name, which keeps the uniform
+// reset of random weights in [MinRandWeight, MaxRandWeight].
+func MutationByName(name string) (Mutation, error) {
+	base, param := name, ""
+	if i := strings.Index(name, ":"); i >= 0 {
+		base, param = name[:i], name[i+1:]
+	}
+	switch base {
+	case "":
+		return nil, nil
+	case "gauss":
+		m := GaussianMutation{Sigma: 1}
+		if err := parseParams(param, &m.Sigma, &m.Rate); err != nil {
+			return nil, err
+		}
+		return m, nil
+	case "adaptive":
+		m := SelfAdaptiveMutation{Sigma: 1}
+		if err := parseParams(param, &m.Sigma, &m.Rate, &m.Tau); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unknown mutation %q", name)
+}
+
+// SetMutation picks how children are mutated, nil keeps the uniform reset
+// of weights.
+func (g *Genetic) SetMutation(m Mutation) *Genetic {
+	g.Config.Mutation = ""
+	if m != nil {
+		g.Config.Mutation = m.Name()
+	}
+	g.mut = m
+	return g
+}
+
+func (g *Genetic) mutation() Mutation {
+	if g.mut == nil && g.Config.Mutation != "" {
+		g.mut, _ = MutationByName(g.Config.Mutation)
+	}
+	return g.mut
+}
+
+// mutateNet mutates a child with the configured operator, or resets times
+// random weights without one. The step scales the reset range around its
+// middle.
+func (g *Genetic) mutateNet(rng *rand.Rand, m Mutation, n *NetPerc, times int) {
+	if m != nil {
+		m.Mutate(rng, n, g.step())
+		return
+	}
+	min, max := g.Config.MinRandWeight, g.Config.MaxRandWeight
+	if s := g.step(); s != 1 {
+		mid, half := (min+max)/2, (max-min)/2*s
+		min, max = mid-half, mid+half
+	}
+	for i := 0; i < times; i++ {
+		n.mutateWeight(rng, min, max)
+	}
+}
+
+func (g *Genetic) step() float64 {
+	return orDefault(g.Step, 1)
+}
+
+// adaptStep is the 1/5th success rule: after every Config.StepWindow
+// generations the step grows when more than a fifth of them found a new
+// best and shrinks when fewer did.
+func (g *Genetic) adaptStep(success bool) {
+	if g.Config.StepWindow < 1 {
+		return
+	}
+	g.tries++
+	if success {
+		g.successes++
+	}
+	if g.tries < g.Config.StepWindow {
+		return
+	}
+	const c = 0.817
+	rate := float64(g.successes) / float64(g.tries)
+	switch {
+	case rate > 0.2:
+		g.Step = g.step() / c
+	case rate < 0.2:
+		g.Step = g.step() * c
+	}
+	g.successes, g.tries = 0, 0
+}
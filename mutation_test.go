@@ -0,0 +1,139 @@
+package neuro
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestMutation(t *testing.T) {
+	cases := []Mutation{
+		GaussianMutation{Sigma: 0.5, Rate: 1},
+		GaussianMutation{Sigma: 1},
+		SelfAdaptiveMutation{Sigma: 0.5, Rate: 1, Tau: 0.3},
+		SelfAdaptiveMutation{Sigma: 1},
+	}
+	rng := rand.New(rand.NewSource(1))
+	for _, m := range cases {
+		name := m.Name()
+		parent, _ := testParents()
+		before := snapshot(parent)
+		child := parent.Copy()
+		for i := 0; i < 10; i++ {
+			m.Mutate(rng, child, 1)
+		}
+		if !sameFloats(snapshot(parent), before) {
+			t.Errorf("%s: changed the parent", name)
+		}
+		after := snapshot(child)
+		if len(after) != len(before) {
+			t.Fatalf("%s: %d slices, want %d", name, len(after), len(before))
+		}
+		for i := range after {
+			if len(after[i]) != len(before[i]) {
+				t.Fatalf("%s: slice %d has %d values, want %d", name, i, len(after[i]), len(before[i]))
+			}
+		}
+		if sameFloats(after, before) {
+			t.Errorf("%s: changed nothing", name)
+		}
+		if byName, err := MutationByName(name); err != nil || byName != m {
+			t.Errorf("%s: by name gives %v, %v", name, byName, err)
+		}
+	}
+}
+
+func TestSelfAdaptiveSigma(t *testing.T) {
+	m := SelfAdaptiveMutation{Sigma: 0.5}
+	n, _ := testParents()
+	m.Mutate(rand.New(rand.NewSource(1)), n, 1)
+	if n.Sigma <= 0 || n.Sigma == 0.5 {
+		t.Fatalf("sigma %g after one mutation", n.Sigma)
+	}
+	sigma := n.Sigma
+	if c := n.Copy(); c.Sigma != sigma {
+		t.Errorf("copy has sigma %g, want %g", c.Sigma, sigma)
+	}
+	m.Mutate(rand.New(rand.NewSource(2)), n, 1)
+	if n.Sigma == sigma {
+		t.Error("sigma did not change")
+	}
+}
+
+func TestMutationByName(t *testing.T) {
+	if m, err := MutationByName(""); m != nil || err != nil {
+		t.Errorf("empty name gives %v, %v", m, err)
+	}
+	for _, name := range []string{"flip", "gauss:x", "gauss:1:2:3", "adaptive:1:1:1:1"} {
+		if _, err := MutationByName(name); err == nil {
+			t.Errorf("%q: no error", name)
+		}
+	}
+}
+
+func TestAdaptStep(t *testing.T) {
+	cases := []struct {
+		hits []bool
+		grow bool
+		same bool
+	}{
+		{[]bool{true, true, false, false, false}, true, false},
+		{[]bool{false, false, false, false, false}, false, false},
+		{[]bool{true, false, false, false, false}, false, true},
+	}
+	for i, c := range cases {
+		g := InitGenetic(GeneticConf{StepWindow: len(c.hits)})
+		for k, hit := range c.hits {
+			if k < len(c.hits)-1 && g.step() != 1 {
+				t.Fatalf("case %d: step moved before the window was full", i)
+			}
+			g.adaptStep(hit)
+		}
+		switch {
+		case c.same && g.step() != 1:
+			t.Errorf("case %d: step %g, want 1", i, g.step())
+		case !c.same && c.grow && g.step() <= 1:
+			t.Errorf("case %d: step %g did not grow", i, g.step())
+		case !c.same && !c.grow && g.step() >= 1:
+			t.Errorf("case %d: step %g did not shrink", i, g.step())
+		}
+	}
+	g := InitGenetic(GeneticConf{})
+	g.adaptStep(true)
+	if g.step() != 1 {
+		t.Errorf("step %g without a window", g.step())
+	}
+}
+
+func TestResetStep(t *testing.T) {
+	g := InitGenetic(GeneticConf{MinRandWeight: 1, MaxRandWeight: 3})
+	rng := rand.New(rand.NewSource(1))
+	for _, step := range []float64{0, 0.1, 2} {
+		g.Step = step
+		lo, hi := 1.0, 3.0
+		if step != 0 {
+			lo, hi = 2-step, 2+step
+		}
+		n, _ := testParents()
+		for _, l := range n.Dense {
+			for k := range l.Weights {
+				l.Weights[k] = 100
+			}
+			for k := range l.Biases {
+				l.Biases[k] = 100
+			}
+		}
+		g.mutateNet(rng, nil, n, 200)
+		var min, max float64 = 100, -100
+		for _, fls := range snapshot(n) {
+			for _, w := range fls {
+				if w != 100 {
+					min, max = math.Min(min, w), math.Max(max, w)
+				}
+			}
+		}
+		if min < lo || max > hi || max-min < (hi-lo)/2 {
+			t.Errorf("step %g: weights reset within [%g, %g], want [%g, %g]", step, min, max, lo, hi)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package neuro
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"math"
@@ -40,29 +43,59 @@ type Result struct {
 }
 
 type NetPerc struct {
-	Layers      int         `json:"layer"`
-	Neurons     int         `json:"neurons"`
-	Inps        int         `json:"inps"`
-	Outs        int         `json:"outs"`
-	Iters       int         `json:"iters"`
-	CurrInd     int         `json:"curr_ind"`
-	Error       float64     `json:"error"`
-	LearnRate   float64     `json:"learn_rate"`
-	LastPrice   float64     `json:"last_price"`
-	Result      Result      `json:"result"`
-	Bias        bool        `json:"bias"`
-	FinalAct    bool        `json:"final_act"`
-	Regress     bool        `json:"regress"`
-	Budget      float64     `json:"budget"`
-	DiffPerce   float64     `json:"diff_perce"`
-	StatusBSell bool        `json:"status_buy_sell"`
-	ErrorArr    []float64   `json:"error_arr"`
-	RandWeights []float64   `json:"random_waights"`
-	Data        []DataTeach `json:"data"`
-	Net         [][]*Perc   `json:"net"`
-	Score       float64     `json:"score"`
-	Nols        int         `json:"nols"`
-	Trades      int         `json:"trades"`
+	Layers      int            `json:"layer"`
+	Neurons     int            `json:"neurons"`
+	Inps        int            `json:"inps"`
+	Outs        int            `json:"outs"`
+	Iters       int            `json:"iters"`
+	CurrInd     int            `json:"curr_ind"`
+	Error       float64        `json:"error"`
+	LearnRate   float64        `json:"learn_rate"`
+	LastPrice   float64        `json:"last_price"`
+	Result      Result         `json:"result"`
+	Bias        bool           `json:"bias"`
+	FinalAct    bool           `json:"final_act"`
+	Softmax     bool           `json:"softmax"`
+	Regress     bool           `json:"regress"`
+	Budget      float64        `json:"budget"`
+	DiffPerce   float64        `json:"diff_perce"`
+	StatusBSell bool           `json:"status_buy_sell"`
+	ErrorArr    []float64      `json:"error_arr"`
+	RandWeights []float64      `json:"random_waights"`
+	Data        []DataTeach    `json:"data"`
+	Net         [][]*Perc      `json:"net,omitempty"`
+	Dense       []*Layer       `json:"dense"`
+	Score       float64        `json:"score"`
+	Nols        int            `json:"nols"`
+	Trades      int            `json:"trades"`
+	Returns     []float64      `json:"-"`
+	Activations []string       `json:"activations"`
+	Specs       []LayerSpec    `json:"specs"`
+	TrainConf   TrainConf      `json:"train_conf"`
+	Optim       OptimizerState `json:"optimizer"`
+	Epoch       int            `json:"epoch"`
+	ValidError  float64        `json:"valid_error"`
+	BestEpoch   int            `json:"best_epoch"`
+	Schedule    Schedule       `json:"-"`
+	Callbacks   []Callback     `json:"-"`
+	LossName    string         `json:"loss"`
+	Norm        *Norm          `json:"norm,omitempty"`
+	Sigma       float64        `json:"sigma,omitempty"`
+	acts        []Activation
+	input       []float64
+	normed      []float64
+	order       []int
+	lastLoss    float64
+	loss        Loss
+	callbacks   []Callback
+	curRate     float64
+	rng         *rand.Rand
+}
+
+type LayerSpec struct {
+	Neurons int        `json:"neurons"`
+	Act     Activation `json:"-"`
+	Bias    bool       `json:"bias"`
 }
 
 var mtx sync.Mutex
@@ -72,20 +105,52 @@ func InitNetPerc(layer, neurons int) *NetPerc {
 		Layers:  layer,
 		Neurons: neurons,
 		Result:  Result{},
-		Net:     [][]*Perc{},
 	}
 	n.SetFinAct(true)
 	n.SetBias(false)
 	return n
 }
 
+func InitNetPercLayers(specs ...LayerSpec) *NetPerc {
+	n := InitNetPerc(len(specs), 0)
+	n.Specs = specs
+	for _, sp := range specs {
+		if sp.Neurons > n.Neurons {
+			n.Neurons = sp.Neurons
+		}
+	}
+	return n
+}
+
+func (n *NetPerc) hiddenSpecs() []LayerSpec {
+	if len(n.Specs) > 0 {
+		return n.Specs
+	}
+	specs := make([]LayerSpec, n.Layers)
+	for i := range specs {
+		specs[i] = LayerSpec{Neurons: n.Neurons, Bias: n.Bias}
+	}
+	return specs
+}
+
 func (n *NetPerc) SetRegress(reg bool) *NetPerc {
 	n.Regress = reg
 	return n
 }
 
 func (n *NetPerc) SetFinAct(act bool) *NetPerc {
-	n.FinalAct = act
+	if n.FinalAct != act {
+		n.FinalAct = act
+		n.setOutputAct()
+	}
+	return n
+}
+
+func (n *NetPerc) SetSoftmax(soft bool) *NetPerc {
+	if n.Softmax != soft {
+		n.Softmax = soft
+		n.setOutputAct()
+	}
 	return n
 }
 
@@ -99,65 +164,44 @@ func (n *NetPerc) LRate(rate float64) *NetPerc {
 	return n
 }
 
-func (n *NetPerc) CreateNetGraph(data []DataTeach, out int) *NetPerc {
+func (n *NetPerc) CreateNetGraph(data []DataTeach, out int, acts ...Activation) *NetPerc {
 	n.Inps = len(data[0].Inputs)
 	n.Outs = out
 	n.SetDataAll(data)
 	n.SetFinAct(false)
 	n.SetBias(false)
 	n.SetRegress(true)
-	var inps, outs []*Perc
-	for i := 0; i < n.Inps; i++ {
-		inps = append(inps, &Perc{Start: true})
-	}
-	if n.Bias {
-		inps = append(inps, n.AddBias())
-	}
-	n.Net = append(n.Net, inps)
-	for i := 0; i < n.Layers; i++ {
-		perces := []*Perc{}
-		for p := 0; p < n.Neurons; p++ {
-			perces = append(perces, &Perc{})
-		}
-		if n.Bias {
-			perces = append(perces, n.AddBias())
-		}
-		n.Net = append(n.Net, perces)
-	}
-	for i := 0; i < n.Outs; i++ {
-		outs = append(outs, &Perc{Final: true})
-	}
-	n.Net = append(n.Net, outs)
-	return n.InitWeight()
+	n.setActivations(acts)
+	return n.buildNet()
 }
 
-func (n *NetPerc) CreateNet(data []DataTeach, iteration int) *NetPerc {
+func (n *NetPerc) CreateNet(data []DataTeach, iteration int, acts ...Activation) *NetPerc {
 	n.Inps = len(data[0].Inputs)
 	n.Outs = len(data[0].Outputs)
 	n.Iters = iteration
 	n.SetDataAll(data)
-	var inps, outs []*Perc
-	for i := 0; i < n.Inps; i++ {
-		inps = append(inps, &Perc{Start: true})
-	}
-	if n.Bias {
-		inps = append(inps, n.AddBias())
-	}
-	n.Net = append(n.Net, inps)
-	for i := 0; i < n.Layers; i++ {
-		perces := []*Perc{}
-		for p := 0; p < n.Neurons; p++ {
-			perces = append(perces, &Perc{})
-		}
-		if n.Bias {
-			perces = append(perces, n.AddBias())
+	n.setActivations(acts)
+	return n.buildNet()
+}
+
+// buildNet lays out n.Dense from the hidden layer specs. Every spec's bias
+// flag gives its layer a bias vector, the output layer follows n.Bias.
+func (n *NetPerc) buildNet() *NetPerc {
+	specs := n.hiddenSpecs()
+	for i, sp := range specs {
+		if sp.Act != nil {
+			n.Activations[i] = sp.Act.Name()
+			n.acts[i] = sp.Act
 		}
-		n.Net = append(n.Net, perces)
 	}
-	for i := 0; i < n.Outs; i++ {
-		outs = append(outs, &Perc{Final: true})
+	n.Net = nil
+	n.Dense = nil
+	in := n.Inps
+	for _, sp := range specs {
+		n.Dense = append(n.Dense, newLayer(in, sp.Neurons, sp.Bias))
+		in = sp.Neurons
 	}
-	n.Net = append(n.Net, outs)
+	n.Dense = append(n.Dense, newLayer(in, n.Outs, n.Bias))
 	return n.InitWeight()
 }
 
@@ -181,6 +225,9 @@ func (n *NetPerc) Operate(rsp []float64, dt DataTeach) {
 	}
 	if rsp[2] == 1 {
 		if n.StatusBSell {
+			if n.LastPrice != 0 {
+				n.Returns = append(n.Returns, (dt.Price-n.LastPrice)/n.LastPrice)
+			}
 			n.DiffPerce += dt.Price - n.LastPrice
 			n.Budget = n.Budget + dt.Price
 			n.Trades = n.Trades + 1
@@ -198,21 +245,16 @@ func (n *NetPerc) InitWeight() *NetPerc {
 	if len(n.RandWeights) < 2 {
 		n.RandWeights = []float64{-10, 10}
 	}
-	for i, els := range n.Net {
-		for _, el := range els {
-			if len(n.Net) != i+1 {
-				if n.Bias {
-					if len(n.Net)-2 == i {
-						el.Weights = n.getWeightsArr(len(n.Net[i+1]))
-					} else {
-						el.Weights = n.getWeightsArr(len(n.Net[i+1]) - 1)
-					}
-				} else {
-					el.Weights = n.getWeightsArr(len(n.Net[i+1]))
-				}
-			}
+	n.prepare()
+	for _, l := range n.Dense {
+		l.Weights = n.getWeightsArr(l.In * l.Out)
+		if l.Bias {
+			l.Biases = n.getWeightsArr(l.Out)
+		} else {
+			l.Biases = make([]float64, l.Out)
 		}
 	}
+	n.linkNet()
 	return n
 }
 
@@ -245,20 +287,6 @@ func (n *NetPerc) nextData() {
 	}
 }
 
-func (n *NetPerc) setInputs() {
-	for i, el := range n.Net[0] {
-		if !el.Bias {
-			el.Value = n.getData().Inputs[i]
-		}
-	}
-}
-
-func (n *NetPerc) setInps(data []float64) {
-	for i, el := range n.Net[0] {
-		el.Value = data[i]
-	}
-}
-
 func (n *NetPerc) Accuracy(min float64) bool {
 	return n.Result.Percent >= min
 }
@@ -269,7 +297,11 @@ func (n *NetPerc) getData() DataTeach {
 
 func (n *NetPerc) logIter(i, iter int) {
 	if i%iter == 0 {
-		log.Println("iteration: ", i, "; error: ", n.Error)
+		if n.ValidError != 0 {
+			log.Println("iteration: ", i, "; error: ", n.Error, "; valid error: ", n.ValidError)
+		} else {
+			log.Println("iteration: ", i, "; error: ", n.Error)
+		}
 	}
 }
 
@@ -281,160 +313,133 @@ func (n *NetPerc) derivative(val float64) float64 {
 	return val * (1 - val)
 }
 
-var mtxPre sync.Mutex
-
-func (p *Perc) addVals(preVal float64) {
-	if p.PreVals == nil {
-		p.PreVals = []float64{}
-	}
-	p.PreVals = append(p.PreVals, preVal)
-}
-
-func (p *Perc) sigmoid(v float64) float64 {
-	return 1.0 / (1.0 + math.Exp(-v))
-}
-
-func (p *Perc) activation() {
-	if len(p.PreVals) > 0 {
-		var tm float64
-		for _, v := range p.PreVals {
-			tm += v
-		}
-		p.Value = p.sigmoid(tm)
-		p.PreVals = []float64{}
-	}
+func (n *NetPerc) forwardPass() {
+	n.forward(n.getData().Inputs)
 }
 
-func (p *Perc) activationWithOutAct() {
-	if len(p.PreVals) > 0 {
-		var tm float64
-		for _, v := range p.PreVals {
-			tm += v
+func (n *NetPerc) forward(inputs []float64) []float64 {
+	n.prepare()
+	x := inputs
+	if n.Norm != nil {
+		if len(n.normed) != len(n.Norm.Mean) {
+			n.normed = make([]float64, len(n.Norm.Mean))
 		}
-		p.Value = tm
-		p.PreVals = []float64{}
+		x = n.Norm.apply(x, n.normed)
 	}
-}
-
-func (n *NetPerc) forwardPass() {
-	for il, layer := range n.Net {
-		for _, perc := range layer {
-			if len(n.Net)-1 == il {
-				if n.FinalAct {
-					perc.activation()
-				} else {
-					perc.activationWithOutAct()
-				}
-			} else {
-				perc.activation()
-			}
-			if len(perc.Weights) > 0 {
-				for iw, weight := range perc.Weights {
-					n.Net[il+1][iw].addVals(perc.Value * weight)
-				}
-			}
-		}
+	n.input = x
+	for il, l := range n.Dense {
+		x = l.forward(x, n.layerAct(il))
 	}
+	return x
 }
 
-func (n *NetPerc) getOut(index int) *Perc {
-	return n.Net[len(n.Net)-1][index]
-}
-
-func (n *NetPerc) getOuts() []*Perc {
-	return n.Net[len(n.Net)-1]
+func (n *NetPerc) getOuts() []float64 {
+	return n.Dense[len(n.Dense)-1].out
 }
 
 func (n *NetPerc) calcErrorIter() {
-	var allErr float64
-	for i, o := range n.getData().Outputs {
-		perc := n.getOut(i)
-		perc.Error = o - perc.Value
-		allErr += math.Pow(perc.Error, 2)
-	}
-	n.Error = allErr
+	n.Error = n.outputError(n.getData().Outputs)
 }
 
 func (n *NetPerc) calcError() {
 
 	// main error
-	var allErr float64
-	for i, o := range n.getData().Outputs {
-		perc := n.getOut(i)
-		perc.Error = o - perc.Value
-		allErr += math.Pow(perc.Error, 2)
-	}
+	allErr := n.outputError(n.getData().Outputs)
 	n.Error = toFixed(allErr, 10)
 	if n.ErrorArr == nil {
 		n.ErrorArr = []float64{}
 	}
 	n.ErrorArr = append(n.ErrorArr, allErr)
 
-	// other perc error
-	for il := len(n.Net) - 2; il > 0; il-- {
-		for _, perc := range n.Net[il] {
-			perc.Error = 0
-			for iw, weight := range perc.Weights {
-				perc.Error += weight * n.Net[il+1][iw].Error
-			}
-			perc.Error = perc.Error * perc.proizvod()
-		}
+	// hidden layers error
+	for il := len(n.Dense) - 1; il > 0; il-- {
+		n.Dense[il].backward(n.Dense[il-1], n.layerAct(il-1))
 	}
 
 }
 
 func (n *NetPerc) backPropogation() {
-	for il, layer := range n.Net {
-		for _, perc := range layer {
-			for iw, weight := range perc.Weights {
-				newWeight := weight + n.LearnRate*n.Net[il+1][iw].Error*perc.Value //*n.Net[il+1][iw].proizvod()
-				perc.Weights[iw] = newWeight
-			}
-		}
+	x := n.input
+	for _, l := range n.Dense {
+		l.accumulate(x)
+		x = l.out
 	}
 }
 
-func (p *Perc) proizvod() float64 {
-	return p.Value * (1 - p.Value)
-}
-
 func (n *NetPerc) Train(showIter ...int) {
 	var iter int
-	start := time.Now()
 	if len(showIter) > 0 {
 		iter = showIter[0]
 	} else {
 		iter = 50
 	}
-	n.setInputs()
-	for i := 0; i < n.Iters; i++ {
+	if err := n.train(context.Background(), iter); err != nil {
+		log.Println("train:", err)
+	}
+}
+
+// TrainCtx is Train that stops once ctx is done. The net keeps the weights
+// reached so far, or the best validation weights with RestoreBest, and
+// ctx.Err() is returned.
+func (n *NetPerc) TrainCtx(ctx context.Context, showIter ...int) error {
+	iter := 50
+	if len(showIter) > 0 {
+		iter = showIter[0]
+	}
+	return n.train(ctx, iter)
+}
 
-		for e := 0; e < len(n.Data); e++ {
-			// ===========================
-			n.setInputs()
-			// ===========================
-			n.forwardPass()
-			// ===========================
-			n.calcError()
-			// ===========================
-			n.backPropogation()
-			// ===========================
-			n.nextData()
-			// ===========================
+// train runs n.Iters epochs. Without callbacks progress is logged every
+// iter epochs.
+func (n *NetPerc) train(ctx context.Context, iter int) error {
+	start := time.Now()
+	n.callbacks = n.Callbacks
+	if len(n.callbacks) == 0 {
+		n.callbacks = []Callback{LogCallback{Every: iter}}
+	}
+	valid, data := n.splitValidation()
+	all := n.Data
+	n.Data = data
+	stop := earlyStop{best: math.Inf(1)}
+	n.ValidError = 0
+	var err error
+	for i := 0; i < n.Iters; i++ {
+		m := EpochMetrics{Epoch: i, Iters: n.Iters, Rate: n.curRate}
+		if err = n.emitEpoch(true, m); err != nil {
+			break
+		}
+		if err = n.trainEpoch(ctx); err != nil {
+			break
 		}
 		n.calcMainErrorDataSet()
-		n.logIter(i, iter)
-		n.CurrInd = 0
-
+		if len(valid) > 0 {
+			n.ValidError = n.calcDataSetError(valid)
+			m.EarlyStop = stop.check(n)
+		}
+		m.Error = n.Error
+		m.ValidError = n.ValidError
+		m.BestValid = stop.best
+		m.Rate = n.curRate
+		m.Elapsed = time.Since(start)
+		m.Last = m.EarlyStop || i == n.Iters-1
+		if err = n.emitEpoch(false, m); err != nil || m.EarlyStop {
+			break
+		}
+	}
+	if len(valid) > 0 && n.TrainConf.RestoreBest {
+		stop.restore(n)
 	}
+	n.Data = all
 	n.ErrorArr = []float64{}
 	//n.Data = nil
-	log.Println("teach time:", time.Now().Sub(start).String())
+	if err == ErrStop {
+		return nil
+	}
+	return err
 }
 
 func (n *NetPerc) TrainIter() {
-	n.CurrInd = randInt(len(n.Data) - 1)
-	n.setInputs()
+	n.CurrInd = n.random().Intn(len(n.Data) - 1)
 	// ===========================
 	n.forwardPass()
 	// ===========================
@@ -456,25 +461,12 @@ func (n *NetPerc) DataCopy() *NetPerc {
 }
 
 func (n *NetPerc) TrainIters() *NetPerc {
-	for e := 0; e < len(n.Data); e++ {
-		// ===========================
-		n.setInputs()
-		// ===========================
-		n.forwardPass()
-		// ===========================
-		n.calcError()
-		// ===========================
-		n.backPropogation()
-		// ===========================
-		n.nextData()
-		// ===========================
-	}
+	_ = n.trainEpoch(context.Background())
 	n.calcMainErrorDataSet()
 	return n
 }
 func (n *NetPerc) TrainItersNew() *NetPerc {
-	n.CurrInd = randInt(len(n.Data) - 1)
-	n.setInputs()
+	n.CurrInd = n.random().Intn(len(n.Data) - 1)
 	n.forwardPass()
 	n.calcErrorIter()
 	n.calcMainErrorDataSet()
@@ -496,32 +488,33 @@ func (n *NetPerc) calcMainErrorDataSet() {
 
 func (n *NetPerc) PredictClear(data []float64) []float64 {
 	n.CurrInd = 0
-	n.setInps(data)
-	n.forwardPass()
-	var response []float64
-	for _, perc := range n.getOuts() {
-		if n.Regress {
-			response = append(response, toFixed(perc.Value, 3))
-		} else {
-			//response = append(response, roundFl(toFixed(perc.Value, 3)))
-			response = append(response, perc.Value)
-		}
-	}
-	return sortedFls(response)
+	out := n.forward(data)
+	response := make([]float64, len(out))
+	response[argmax(out)] = 1
+	return response
 }
 
-func sortedFls(fls []float64) []float64 {
-	var max float64
+// PredictProba returns the raw outputs, class probabilities for a softmax
+// output layer.
+func (n *NetPerc) PredictProba(data []float64) []float64 {
+	out := n.forward(data)
+	response := make([]float64, len(out))
+	copy(response, out)
+	return response
+}
+
+func (n *NetPerc) PredictClass(data []float64) int {
+	return argmax(n.forward(data))
+}
+
+func argmax(fls []float64) int {
 	var maxI int
-	for i := len(fls) - 1; i >= 0; i-- {
-		if fls[i] > max {
-			max = fls[i]
+	for i, fl := range fls {
+		if fl > fls[maxI] {
 			maxI = i
 		}
 	}
-	flRet := make([]float64, len(fls))
-	flRet[maxI] = 1
-	return flRet
+	return maxI
 }
 
 func (n *NetPerc) clearData() {
@@ -532,14 +525,13 @@ func (n *NetPerc) Predict(data []float64) []float64 {
 	n.Data = nil
 	n.CurrInd = 0
 	n.SetData(DataTeach{Inputs: data})
-	n.setInputs()
 	n.forwardPass()
 	var response []float64
-	for _, perc := range n.getOuts() {
+	for _, val := range n.getOuts() {
 		if n.Regress {
-			response = append(response, toFixed(perc.Value, 3))
+			response = append(response, toFixed(val, 3))
 		} else {
-			response = append(response, roundFl(perc.Value))
+			response = append(response, roundFl(val))
 		}
 	}
 	return response
@@ -557,14 +549,13 @@ func (n *NetPerc) PredictBot(data []float64, last ...int) []float64 {
 	n.Data = nil
 	n.CurrInd = 0
 	n.SetData(DataTeach{Inputs: data})
-	n.setInputs()
 	n.forwardPass()
 	var response []float64
-	for _, perc := range n.getOuts() {
+	for _, val := range n.getOuts() {
 		if len(last) > 0 {
-			response = append(response, toFixed(perc.Value, last[0]))
+			response = append(response, toFixed(val, last[0]))
 		} else {
-			response = append(response, toFixed(perc.Value, 3))
+			response = append(response, toFixed(val, 3))
 		}
 	}
 	return response
@@ -596,7 +587,7 @@ func (n *NetPerc) CalcStatAll(data []*DataTeach) *NetPerc {
 
 func (n *NetPerc) CalcStatRegress(data []*DataTeach, count int) *NetPerc {
 	for i := 0; i < count; i++ {
-		index := randInt(len(data))
+		index := n.random().Intn(len(data))
 		inp := n.Predict(data[index].Inputs)
 		out := data[index].Outputs
 		for in, fl := range inp {
@@ -618,7 +609,7 @@ func (n *NetPerc) CalcStat(count int) *NetPerc {
 	defer mtxCalc.Unlock()
 	//n.Result = Result{}
 	for i := 0; i < count; i++ {
-		index := randInt(len(n.Data) - 1)
+		index := n.random().Intn(len(n.Data) - 1)
 		n.Equal(n.PredictClear(n.Data[index].Inputs), n.Data[index].Outputs)
 	}
 	n.Result.Percent = (float64(n.Result.True-n.Result.False) / (float64(n.Result.False+n.Result.True) / 2) * 100) / 2
@@ -629,60 +620,83 @@ func (n *NetPerc) GetStat() string {
 	return "accuracy: " + fmt.Sprintf("%.2f", toFixed(n.Result.Percent, 1)) + "%"
 }
 
+// Save writes the net to a temporary file next to fileName and renames it
+// into place, so a crash while saving keeps the previous file intact.
 func (n *NetPerc) Save(fileName string) error {
 	if fileName == "" {
 		return errors.New("empty filename")
 	}
-	if bts, err := json.Marshal(n); err != nil {
+	return writeFile(fileName, n.Encode)
+}
+
+// Encode writes the net as checksummed JSON, the same content as Save.
+func (n *NetPerc) Encode(w io.Writer) error {
+	bts, err := n.encode()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(bts)
+	return err
+}
+
+// Decode replaces n with the net read from r. It accepts everything
+// LoadNet does.
+func (n *NetPerc) Decode(r io.Reader) error {
+	bts, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(bts, binaryMagic) {
+		return n.UnmarshalBinary(bts)
+	}
+	net, err := decodeNet(bts)
+	if err != nil {
 		return err
-	} else {
-		if err := ioutil.WriteFile(fileName, bts, 0644); err != nil {
-			return err
-		}
 	}
+	*n = *net
 	return nil
 }
 
+// LoadNet reads a file written by Save or SaveBinary. Unversioned dumps of
+// older releases are migrated, a damaged file or one that does not describe
+// a usable net is rejected with an error.
 func LoadNet(fileName string) (*NetPerc, error) {
-	var net NetPerc
 	if fileName == "" {
-		return &net, errors.New("empty filename")
+		return &NetPerc{}, errors.New("empty filename")
 	}
-	bts, err := ioutil.ReadFile(fileName)
+	f, err := os.Open(fileName)
 	if err != nil {
-		return &net, err
+		return &NetPerc{}, err
 	}
-	if err := json.Unmarshal(bts, &net); err != nil {
-		return &net, err
+	defer f.Close()
+	net := &NetPerc{}
+	if err := net.Decode(f); err != nil {
+		return &NetPerc{}, fmt.Errorf("load %s: %v", fileName, err)
 	}
-	return &net, nil
+	return net, nil
 }
 
 func (n *NetPerc) getWeights(val float64, length int) []float64 {
-	return randFloats(n.RandWeights[0], n.RandWeights[1], length)
+	return randFloats(n.random(), n.RandWeights[0], n.RandWeights[1], length)
 }
 
 func (n *NetPerc) getWeightsArr(length int) []float64 {
-	return randFloats(n.RandWeights[0], n.RandWeights[1], length)
+	return randFloats(n.random(), n.RandWeights[0], n.RandWeights[1], length)
 }
 
-func randFloats(min, max float64, n int) []float64 {
+func randFloats(rng *rand.Rand, min, max float64, n int) []float64 {
 	res := make([]float64, n)
 	for i := range res {
-		res[i] = toFixed(min+rand.Float64()*(max-min), 3)
+		res[i] = toFixed(min+rng.Float64()*(max-min), 3)
 	}
 	return res
 }
 
-func randFloat(min, max float64) float64 {
-	return min + rand.Float64()*(max-min)
+func randFloat(rng *rand.Rand, min, max float64) float64 {
+	return min + rng.Float64()*(max-min)
 }
 
-func randInt(max int) int {
-	return rand.Intn(max)
-}
-
-func randIntMin(min, max int) int {
+func randIntMin(rng *rand.Rand, min, max int) int {
 	defer func() {
 		if msg := recover(); msg != nil {
 			fmt.Println(min, max)
@@ -707,7 +721,7 @@ func randIntMin(min, max int) int {
 	if max-min == 0 {
 		return 0
 	}
-	return rand.Intn(max-min) + min
+	return rng.Intn(max-min) + min
 }
 
 func getDiff(newPrice, oldPrice float64) float64 {
@@ -727,75 +741,60 @@ func round(num float64) int {
 	return int(num + math.Copysign(0.5, num))
 }
 
+// Copy returns a deep copy of the net and its data. Like a saved net it
+// has no callbacks, schedule, validation data or random generator; Error
+// is reset to 1 and ErrorArr and Result are cleared.
 func (n *NetPerc) Copy() *NetPerc {
-	var nn NetPerc
-	bts, err := json.Marshal(n)
-	if err != nil {
-		log.Fatal(err)
-	}
-	if err := json.Unmarshal(bts, &nn); err != nil {
-		log.Fatal(err)
-	}
+	nn := *n
 	nn.Error = 1
 	nn.ErrorArr = []float64{}
 	nn.Result = Result{}
+	nn.Returns = nil
+	nn.RandWeights = copyFloats(n.RandWeights)
+	nn.Activations = append([]string(nil), n.Activations...)
+	nn.Specs = append([]LayerSpec(nil), n.Specs...)
+	nn.Norm = n.Norm.copy()
+	nn.Schedule = nil
+	nn.Callbacks = nil
+	nn.TrainConf.Validation = nil
+	nn.Data = nil
+	for _, dt := range n.Data {
+		dt.Inputs = copyFloats(dt.Inputs)
+		dt.Outputs = copyFloats(dt.Outputs)
+		nn.Data = append(nn.Data, dt)
+	}
+	nn.Net = nil
+	nn.Dense = nil
+	if len(n.Dense) == 0 && len(n.Net) > 1 {
+		nn.Dense = percsToLayers(n.Net)
+	}
+	for _, l := range n.Dense {
+		nn.Dense = append(nn.Dense, l.copy())
+	}
+	nn.linkNet()
+	nn.acts = append([]Activation(nil), n.acts...)
+	nn.input = nil
+	nn.normed = nil
+	nn.order = nil
+	nn.callbacks = nil
+	nn.rng = nil
 	return &nn
-
-	/*
-		nn := NetPerc{}
-		nn.Layers = n.Layers
-		nn.Neurons = n.Neurons
-		nn.Inps = n.Inps
-		nn.Outs = n.Outs
-		nn.Iters = n.Iters
-		nn.CurrInd = n.CurrInd
-		nn.Error = n.Error
-		nn.LearnRate = n.LearnRate
-		nn.FinalAct = n.FinalAct
-		nn.ErrorArr = nil
-		nn.RandWeights = n.RandWeights
-
-		for i, el := range n.Data {
-			dt := DataTeach{
-				Inputs:  make([]float64, len(el.Inputs)),
-				Outputs: make([]float64, len(el.Outputs)),
-			}
-			copy(dt.Inputs, n.Data[i].Inputs)
-			copy(dt.Outputs, n.Data[i].Outputs)
-			nn.Data = append(nn.Data, dt)
-		}
-
-		for _, els := range n.Net {
-			var listPerc []*Perc
-			for _, el := range els {
-				p := Perc{
-					Error: el.Error,
-					Start: el.Start,
-					Final: el.Final,
-					Bias:  el.Bias,
-					Value: el.Value,
-				}
-				p.PreVals = make([]float64, len(el.PreVals))
-				p.Weights = make([]float64, len(el.Weights))
-				copy(p.PreVals, el.PreVals)
-				copy(p.Weights, el.Weights)
-				listPerc = append(listPerc, &p)
-			}
-			nn.Net = append(nn.Net, listPerc)
-		}
-		return &nn
-	*/
-
 }
 
-func (n *NetPerc) mutateWeight(min, max float64) {
-	layer := randInt(n.Layers + 1)
-	per := randInt(len(n.Net[layer]) - 1)
-	weightsLength := 0
-	if len(n.Net[layer][per].Weights)-1 > 0 {
-		weightsLength = randInt(len(n.Net[layer][per].Weights) - 1)
+func (n *NetPerc) mutateWeight(rng *rand.Rand, min, max float64) {
+	n.prepare()
+	l := n.Dense[rng.Intn(len(n.Dense))]
+	cols := l.In
+	if l.Bias {
+		cols++
+	}
+	per := rng.Intn(cols)
+	weightsLength := rng.Intn(l.Out)
+	if per == l.In {
+		l.Biases[weightsLength] = randFloat(rng, min, max)
+		return
 	}
-	n.Net[layer][per].Weights[weightsLength] = randFloat(min, max)
+	l.Weights[per*l.Out+weightsLength] = randFloat(rng, min, max)
 }
 
 func debug(in interface{}) {
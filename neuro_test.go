@@ -0,0 +1,100 @@
+package neuro
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+var xorData = []DataTeach{
+	{Inputs: []float64{0, 0}, Outputs: []float64{0}},
+	{Inputs: []float64{0, 1}, Outputs: []float64{1}},
+	{Inputs: []float64{1, 0}, Outputs: []float64{1}},
+	{Inputs: []float64{1, 1}, Outputs: []float64{0}},
+}
+
+func snapshot(n *NetPerc) [][]float64 {
+	var fls [][]float64
+	for _, l := range n.Dense {
+		fls = append(fls, copyFloats(l.Weights), copyFloats(l.Biases))
+	}
+	return fls
+}
+
+func sameFloats(a, b [][]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for k := range a[i] {
+			if a[i][k] != b[i][k] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestMutateWeightReachesEveryGene(t *testing.T) {
+	n := InitNetPerc(1, 3).SetBias(true).SetSeed(1).SetWeight(-1, 1).CreateNet(xorData, 1)
+	rng := n.Random()
+	seen := make([]map[int]bool, len(n.Dense))
+	for i := range seen {
+		seen[i] = map[int]bool{}
+	}
+	for i := 0; i < 5000; i++ {
+		c := n.Copy()
+		c.mutateWeight(rng, 5, 6)
+		for il, l := range c.Dense {
+			for k, w := range l.Weights {
+				if w != n.Dense[il].Weights[k] {
+					seen[il][k] = true
+				}
+			}
+			for j, b := range l.Biases {
+				if b != n.Dense[il].Biases[j] {
+					seen[il][len(l.Weights)+j] = true
+				}
+			}
+		}
+	}
+	for il, l := range n.Dense {
+		if want := len(l.Weights) + len(l.Biases); len(seen[il]) != want {
+			t.Errorf("layer %d: %d of %d weights and biases mutated", il, len(seen[il]), want)
+		}
+	}
+}
+
+type cancelAt struct {
+	NopCallback
+	epoch  int
+	cancel context.CancelFunc
+}
+
+func (c cancelAt) OnEpochEnd(n *NetPerc, m EpochMetrics) error {
+	if m.Epoch == c.epoch {
+		c.cancel()
+	}
+	return nil
+}
+
+func TestTrainCtx(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := InitNetPerc(1, 4).CreateNet(xorData, 10).AddCallback(cancelAt{epoch: 2, cancel: cancel})
+	if err := n.TrainCtx(ctx); err != context.Canceled {
+		t.Fatalf("cancelled training returned %v", err)
+	}
+	if n.Epoch != 3 {
+		t.Fatalf("trained %d epochs, want 3 before the cancel", n.Epoch)
+	}
+
+	boom := errors.New("boom")
+	n = InitNetPerc(1, 4).CreateNet(xorData, 10).AddCallback(stopAt{epoch: 1, err: boom})
+	if err := n.TrainCtx(context.Background()); err != boom {
+		t.Fatalf("training returned %v, want the callback error", err)
+	}
+}
@@ -0,0 +1,69 @@
+package neuro
+
+import "math"
+
+// Norm standardizes inputs as (x - Mean) / Std before the first layer. It
+// is saved with the net so a loaded model sees inputs scaled the same way
+// as during training.
+type Norm struct {
+	Mean []float64 `json:"mean"`
+	Std  []float64 `json:"std"`
+}
+
+// FitNorm computes the mean and standard deviation of every input over
+// data. Inputs that never change keep a Std of 1.
+func FitNorm(data []DataTeach) *Norm {
+	if len(data) == 0 {
+		return nil
+	}
+	size := len(data[0].Inputs)
+	nr := &Norm{Mean: make([]float64, size), Std: make([]float64, size)}
+	for _, dt := range data {
+		for i, x := range dt.Inputs[:size] {
+			nr.Mean[i] += x
+		}
+	}
+	for i := range nr.Mean {
+		nr.Mean[i] /= float64(len(data))
+	}
+	for _, dt := range data {
+		for i, x := range dt.Inputs[:size] {
+			nr.Std[i] += (x - nr.Mean[i]) * (x - nr.Mean[i])
+		}
+	}
+	for i, s := range nr.Std {
+		nr.Std[i] = math.Sqrt(s / float64(len(data)))
+		if nr.Std[i] == 0 {
+			nr.Std[i] = 1
+		}
+	}
+	return nr
+}
+
+// SetNorm sets the input normalization, nil turns it off.
+func (n *NetPerc) SetNorm(nr *Norm) *NetPerc {
+	n.Norm = nr
+	return n
+}
+
+// FitNorm sets the input normalization from the data of the net.
+func (n *NetPerc) FitNorm() *NetPerc {
+	return n.SetNorm(FitNorm(n.Data))
+}
+
+func (nr *Norm) apply(x, dst []float64) []float64 {
+	if nr == nil {
+		return x
+	}
+	for i, m := range nr.Mean {
+		dst[i] = (x[i] - m) / nr.Std[i]
+	}
+	return dst[:len(nr.Mean)]
+}
+
+func (nr *Norm) copy() *Norm {
+	if nr == nil {
+		return nil
+	}
+	return &Norm{Mean: copyFloats(nr.Mean), Std: copyFloats(nr.Std)}
+}
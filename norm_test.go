@@ -0,0 +1,55 @@
+package neuro
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestFitNorm(t *testing.T) {
+	data := []DataTeach{
+		{Inputs: []float64{1, 5}},
+		{Inputs: []float64{3, 5}},
+	}
+	nr := FitNorm(data)
+	if nr.Mean[0] != 2 || nr.Std[0] != 1 {
+		t.Fatalf("input 0: mean %g std %g, want 2 and 1", nr.Mean[0], nr.Std[0])
+	}
+	// a constant input keeps a std of 1 instead of dividing by zero
+	if nr.Mean[1] != 5 || nr.Std[1] != 1 {
+		t.Fatalf("input 1: mean %g std %g, want 5 and 1", nr.Mean[1], nr.Std[1])
+	}
+	if got := nr.apply([]float64{4, 5}, make([]float64, 2)); got[0] != 2 || got[1] != 0 {
+		t.Fatalf("normalized inputs %v, want [2 0]", got)
+	}
+	if FitNorm(nil) != nil {
+		t.Fatal("norm without data")
+	}
+}
+
+func TestSaveNorm(t *testing.T) {
+	data := benchSet(20, 3, 2)
+	for i := range data {
+		data[i].Inputs[0] *= 100
+	}
+	n := InitNetPerc(1, 4).SetBias(true).SetWeight(-1, 1).CreateNet(data, 1).FitNorm()
+	file := filepath.Join(t.TempDir(), "net.json")
+	if err := n.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadNet(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Norm == nil || !sameFloats([][]float64{loaded.Norm.Mean, loaded.Norm.Std}, [][]float64{n.Norm.Mean, n.Norm.Std}) {
+		t.Fatalf("loaded norm %+v, want %+v", loaded.Norm, n.Norm)
+	}
+	for _, dt := range data {
+		want, got := n.PredictProba(dt.Inputs), loaded.PredictProba(dt.Inputs)
+		for k := range want {
+			if math.Abs(want[k]-got[k]) > 1e-12 {
+				t.Fatalf("loaded net predicts %v, want %v", got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package neuro
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// Optimizer turns averaged gradients into weight updates. Every parameter
+// gets Slots() state values which are stored in the layers and saved with
+// the net, so training can be resumed from a dump.
+type Optimizer interface {
+	Name() string
+	Slots() int
+	Update(params, grads []float64, slots [][]float64, rate float64, step int)
+}
+
+type SGD struct{}
+
+func (SGD) Name() string { return "sgd" }
+func (SGD) Slots() int   { return 0 }
+
+func (SGD) Update(params, grads []float64, slots [][]float64, rate float64, step int) {
+	for i, g := range grads {
+		params[i] -= rate * g
+	}
+}
+
+type Momentum struct {
+	Mu       float64 `json:"mu"`
+	Nesterov bool    `json:"nesterov"`
+}
+
+func (o Momentum) Name() string { return "momentum" }
+func (o Momentum) Slots() int   { return 1 }
+
+func (o Momentum) Update(params, grads []float64, slots [][]float64, rate float64, step int) {
+	mu := orDefault(o.Mu, 0.9)
+	vel := slots[0]
+	for i, g := range grads {
+		vel[i] = mu*vel[i] + g
+		if o.Nesterov {
+			params[i] -= rate * (g + mu*vel[i])
+		} else {
+			params[i] -= rate * vel[i]
+		}
+	}
+}
+
+type RMSProp struct {
+	Rho float64 `json:"rho"`
+	Eps float64 `json:"eps"`
+}
+
+func (o RMSProp) Name() string { return "rmsprop" }
+func (o RMSProp) Slots() int   { return 1 }
+
+func (o RMSProp) Update(params, grads []float64, slots [][]float64, rate float64, step int) {
+	rho, eps := orDefault(o.Rho, 0.9), orDefault(o.Eps, 1e-8)
+	sq := slots[0]
+	for i, g := range grads {
+		sq[i] = rho*sq[i] + (1-rho)*g*g
+		params[i] -= rate * g / (math.Sqrt(sq[i]) + eps)
+	}
+}
+
+type AdaGrad struct {
+	Eps float64 `json:"eps"`
+}
+
+func (o AdaGrad) Name() string { return "adagrad" }
+func (o AdaGrad) Slots() int   { return 1 }
+
+func (o AdaGrad) Update(params, grads []float64, slots [][]float64, rate float64, step int) {
+	eps := orDefault(o.Eps, 1e-8)
+	sq := slots[0]
+	for i, g := range grads {
+		sq[i] += g * g
+		params[i] -= rate * g / (math.Sqrt(sq[i]) + eps)
+	}
+}
+
+type Adam struct {
+	Beta1 float64 `json:"beta1"`
+	Beta2 float64 `json:"beta2"`
+	Eps   float64 `json:"eps"`
+}
+
+func (o Adam) Name() string { return "adam" }
+func (o Adam) Slots() int   { return 2 }
+
+func (o Adam) Update(params, grads []float64, slots [][]float64, rate float64, step int) {
+	b1, b2, eps := orDefault(o.Beta1, 0.9), orDefault(o.Beta2, 0.999), orDefault(o.Eps, 1e-8)
+	m, v := slots[0], slots[1]
+	c1 := 1 - math.Pow(b1, float64(step))
+	c2 := 1 - math.Pow(b2, float64(step))
+	for i, g := range grads {
+		m[i] = b1*m[i] + (1-b1)*g
+		v[i] = b2*v[i] + (1-b2)*g*g
+		params[i] -= rate * (m[i] / c1) / (math.Sqrt(v[i]/c2) + eps)
+	}
+}
+
+func orDefault(val, def float64) float64 {
+	if val == 0 {
+		return def
+	}
+	return val
+}
+
+// OptimizerState is the optimizer of a net together with its update
+// counter. It is saved as {"name", "params", "step"}.
+type OptimizerState struct {
+	Optimizer Optimizer
+	Step      int
+}
+
+func (o OptimizerState) MarshalJSON() ([]byte, error) {
+	opt := o.Optimizer
+	if opt == nil {
+		opt = SGD{}
+	}
+	params, err := json.Marshal(opt)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		Name   string          `json:"name"`
+		Params json.RawMessage `json:"params"`
+		Step   int             `json:"step"`
+	}{opt.Name(), params, o.Step})
+}
+
+func (o *OptimizerState) UnmarshalJSON(bts []byte) error {
+	var raw struct {
+		Name   string          `json:"name"`
+		Params json.RawMessage `json:"params"`
+		Step   int             `json:"step"`
+	}
+	if err := json.Unmarshal(bts, &raw); err != nil {
+		return err
+	}
+	var opt Optimizer
+	switch raw.Name {
+	case "", "sgd":
+		opt = SGD{}
+	case "momentum":
+		var m Momentum
+		if err := unmarshalParams(raw.Params, &m); err != nil {
+			return err
+		}
+		opt = m
+	case "rmsprop":
+		var r RMSProp
+		if err := unmarshalParams(raw.Params, &r); err != nil {
+			return err
+		}
+		opt = r
+	case "adagrad":
+		var a AdaGrad
+		if err := unmarshalParams(raw.Params, &a); err != nil {
+			return err
+		}
+		opt = a
+	case "adam":
+		var a Adam
+		if err := unmarshalParams(raw.Params, &a); err != nil {
+			return err
+		}
+		opt = a
+	default:
+		return fmt.Errorf("unknown optimizer %q", raw.Name)
+	}
+	o.Optimizer = opt
+	o.Step = raw.Step
+	return nil
+}
+
+func unmarshalParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+	return json.Unmarshal(params, v)
+}
+
+// SetOptimizer switches the update rule and drops the state collected by
+// the previous optimizer.
+func (n *NetPerc) SetOptimizer(opt Optimizer) *NetPerc {
+	n.Optim = OptimizerState{Optimizer: opt}
+	for _, l := range n.Dense {
+		l.OptW = nil
+		l.OptB = nil
+	}
+	return n
+}
+
+func (n *NetPerc) optimizer() Optimizer {
+	if n.Optim.Optimizer == nil {
+		return SGD{}
+	}
+	return n.Optim.Optimizer
+}
+
+func optSlots(slots [][]float64, count, size int) [][]float64 {
+	if len(slots) == count {
+		ok := true
+		for _, s := range slots {
+			ok = ok && len(s) == size
+		}
+		if ok {
+			return slots
+		}
+	}
+	slots = make([][]float64, count)
+	for i := range slots {
+		slots[i] = make([]float64, size)
+	}
+	return slots
+}
@@ -0,0 +1,67 @@
+package neuro
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestOptimizerUpdate(t *testing.T) {
+	// two steps from params [1, -2] with grads [0.5, -1] and rate 0.1,
+	// worked out by hand with the default hyper parameters
+	cases := []struct {
+		opt  Optimizer
+		want [2][]float64
+	}{
+		{SGD{}, [2][]float64{{0.95, -1.9}, {0.9, -1.8}}},
+		{Momentum{}, [2][]float64{{0.95, -1.9}, {0.855, -1.71}}},
+		{Momentum{Nesterov: true}, [2][]float64{{0.905, -1.81}, {0.7695, -1.539}}},
+		{RMSProp{}, [2][]float64{{0.683772253983, -1.683772243983}, {0.454356530639, -1.454356515376}}},
+		{AdaGrad{}, [2][]float64{{0.900000002, -1.900000001}, {0.829289324881, -1.829289323381}}},
+		{Adam{}, [2][]float64{{0.900000002, -1.900000001}, {0.800000004, -1.800000002}}},
+	}
+	for _, c := range cases {
+		params := []float64{1, -2}
+		slots := optSlots(nil, c.opt.Slots(), len(params))
+		for step, want := range c.want {
+			c.opt.Update(params, []float64{0.5, -1}, slots, 0.1, step+1)
+			for i := range want {
+				if math.Abs(params[i]-want[i]) > 1e-10 {
+					t.Fatalf("%T%+v step %d: params %v, want %v", c.opt, c.opt, step+1, params, want)
+				}
+			}
+		}
+	}
+}
+
+func TestOptimizerResume(t *testing.T) {
+	n := InitNetPerc(1, 4).SetBias(true).SetWeight(-1, 1).LRate(0.1).
+		SetOptimizer(Adam{}).CreateNet(benchSet(10, 3, 2), 1)
+	whole := n.Copy()
+	for i := 0; i < 6; i++ {
+		whole.TrainIters()
+	}
+
+	half := n.Copy()
+	for i := 0; i < 3; i++ {
+		half.TrainIters()
+	}
+	fileName := filepath.Join(t.TempDir(), "net.json")
+	if err := half.Save(fileName); err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := LoadNet(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed.SetDataAllNew(n.Data)
+	for i := 0; i < 3; i++ {
+		resumed.TrainIters()
+	}
+	if resumed.Optim.Step != whole.Optim.Step {
+		t.Fatalf("resumed at step %d, want %d", resumed.Optim.Step, whole.Optim.Step)
+	}
+	if !sameFloats(snapshot(resumed), snapshot(whole)) {
+		t.Error("training after a reload differs from an uninterrupted run")
+	}
+}
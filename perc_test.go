@@ -0,0 +1,171 @@
+package neuro
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// percForward is the forward pass of the release before Dense, kept to
+// check the Net view and to benchmark against: every neuron collects the
+// weighted values of the previous layer in PreVals.
+func percForward(net [][]*Perc, inputs []float64, finalAct bool) []float64 {
+	for i, x := range inputs {
+		net[0][i].Value = x
+	}
+	for il, layer := range net {
+		for _, p := range layer {
+			if len(p.PreVals) > 0 {
+				var sum float64
+				for _, v := range p.PreVals {
+					sum += v
+				}
+				if il < len(net)-1 || finalAct {
+					sum = 1.0 / (1.0 + math.Exp(-sum))
+				}
+				p.Value = sum
+				p.PreVals = []float64{}
+			}
+			for iw, w := range p.Weights {
+				net[il+1][iw].PreVals = append(net[il+1][iw].PreVals, p.Value*w)
+			}
+		}
+	}
+	out := net[len(net)-1]
+	res := make([]float64, len(out))
+	for i, p := range out {
+		res[i] = p.Value
+	}
+	return res
+}
+
+func TestNetView(t *testing.T) {
+	for _, bias := range []bool{false, true} {
+		n := InitNetPerc(2, 5).SetBias(bias).SetSeed(1).SetWeight(-1, 1).CreateNet(benchSet(4, 3, 2), 1)
+		if len(n.Net) != 4 {
+			t.Fatalf("bias %v: %d layers in Net, want 4", bias, len(n.Net))
+		}
+		for il, l := range n.Dense {
+			want := l.In
+			if bias {
+				want++
+			}
+			if len(n.Net[il]) != want {
+				t.Fatalf("bias %v: layer %d has %d percs, want %d", bias, il, len(n.Net[il]), want)
+			}
+		}
+		for _, c := range []*NetPerc{n, n.Copy()} {
+			for _, dt := range c.Data {
+				want := c.forward(dt.Inputs)
+				got := percForward(c.Net, dt.Inputs, true)
+				for k := range want {
+					if math.Abs(want[k]-got[k]) > 1e-12 {
+						t.Fatalf("bias %v: Net gives %v, Dense %v", bias, got, want)
+					}
+				}
+			}
+		}
+
+		n.Net[1][2].Weights[3] = 7
+		if n.Dense[1].Weights[2*n.Dense[1].Out+3] != 7 {
+			t.Fatalf("bias %v: a weight set through Net did not reach Dense", bias)
+		}
+		if bias {
+			n.Net[0][3].Weights[1] = 8
+			if n.Dense[0].Biases[1] != 8 {
+				t.Fatal("a bias set through Net did not reach Dense")
+			}
+		}
+	}
+}
+
+func TestNetViewAfterLoad(t *testing.T) {
+	n := InitNetPerc(1, 4).SetBias(true).SetSeed(1).CreateNet(xorData, 1)
+	file := filepath.Join(t.TempDir(), "net.json")
+	if err := n.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadNet(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.linked() {
+		t.Fatal("Net of a loaded net does not share the layers")
+	}
+	loaded.Net[0][0].Weights[0] = 9
+	if loaded.Dense[0].Weights[0] != 9 {
+		t.Fatal("a weight set through Net did not reach Dense")
+	}
+}
+
+func BenchmarkForwardPerc(b *testing.B) {
+	n := InitNetPerc(2, 120).SetBias(true).CreateNet(benchSet(64, 10, 3), 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		percForward(n.Net, n.Data[i%len(n.Data)].Inputs, true)
+	}
+}
+
+// percNet is what a net of the old layout carried through a JSON copy.
+type percNet struct {
+	Net   [][]*Perc   `json:"net"`
+	Data  []DataTeach `json:"data"`
+	Score float64     `json:"score"`
+}
+
+// BenchmarkGeneticTrainPerc is a Train generation of the release before
+// Dense, with the settings of BenchmarkGeneticTrain: one random sample per
+// net, keep the best, refill with JSON copies that get random weights.
+func BenchmarkGeneticTrainPerc(b *testing.B) {
+	const population, lastBest, mutateSub = 50, 10, 100
+	data := benchSet(64, 10, 3)
+	rng := rand.New(rand.NewSource(1))
+	var nets []*percNet
+	for i := 0; i < population; i++ {
+		n := InitNetPerc(2, 120).SetBias(true).SetWeight(-1, 1).CreateNet(data, 1)
+		nets = append(nets, &percNet{Net: n.Net, Data: data})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		wg.Add(len(nets))
+		for k, n := range nets {
+			go func(n *percNet, seed int64) {
+				defer wg.Done()
+				dt := n.Data[rand.New(rand.NewSource(seed)).Intn(len(n.Data)-1)]
+				var sum float64
+				for o, v := range percForward(n.Net, dt.Inputs, true) {
+					sum += (dt.Outputs[o] - v) * (dt.Outputs[o] - v)
+				}
+				n.Score = -sum
+			}(n, int64(i*len(nets)+k))
+		}
+		wg.Wait()
+		sort.Slice(nets, func(a, c int) bool { return nets[a].Score > nets[c].Score })
+		nets = nets[:lastBest]
+		children := make([]*percNet, population-lastBest+1)
+		wg.Add(len(children))
+		for s := range children {
+			parent := nets[rng.Intn(lastBest)]
+			go func(s int) {
+				defer wg.Done()
+				bts, _ := json.Marshal(parent)
+				child := &percNet{}
+				json.Unmarshal(bts, child)
+				r := rand.New(rand.NewSource(int64(i*population + s)))
+				for m := 0; m < mutateSub; m++ {
+					layer := child.Net[r.Intn(len(child.Net)-1)]
+					p := layer[r.Intn(len(layer))]
+					p.Weights[r.Intn(len(p.Weights))] = randFloat(r, -1, 1)
+				}
+				children[s] = child
+			}(s)
+		}
+		wg.Wait()
+		nets = append(nets, children...)
+	}
+}
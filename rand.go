@@ -0,0 +1,127 @@
+package neuro
+
+import (
+	"math/rand"
+	"time"
+)
+
+// splitMix is a splitmix64 rand.Source64. Its whole state is one uint64,
+// so a checkpoint can store it and a resumed run draws the same numbers.
+type splitMix struct {
+	state uint64
+}
+
+func (s *splitMix) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *splitMix) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (s *splitMix) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+// globalSource draws from the top level math/rand functions. Nets without
+// a seed or generator of their own use it, as they always did.
+type globalSource struct{}
+
+func (globalSource) Int63() int64    { return rand.Int63() }
+func (globalSource) Uint64() uint64  { return rand.Uint64() }
+func (globalSource) Seed(seed int64) {}
+
+var globalRand = rand.New(globalSource{})
+
+// SetSeed gives the net its own generator, so weight initialization,
+// shuffling and sampling repeat for the same seed.
+func (n *NetPerc) SetSeed(seed int64) *NetPerc {
+	return n.SetRand(rand.New(&splitMix{state: uint64(seed)}))
+}
+
+// SetRand makes the net draw from rng. A rand.Rand is not safe for
+// concurrent use, so every goroutine needs its own.
+func (n *NetPerc) SetRand(rng *rand.Rand) *NetPerc {
+	n.rng = rng
+	return n
+}
+
+// Random returns the generator of the net, for example to sample data in a
+// Genetic.TrainItem function with the stream the run gave this net.
+func (n *NetPerc) Random() *rand.Rand {
+	return n.random()
+}
+
+func (n *NetPerc) random() *rand.Rand {
+	if n.rng == nil {
+		return globalRand
+	}
+	return n.rng
+}
+
+// SetSeed makes the run reproducible: the same seed and population give the
+// same generations. Without a seed one is taken from the clock and stored in
+// Config.Seed.
+func (g *Genetic) SetSeed(seed int64) *Genetic {
+	g.Config.Seed = seed
+	g.src = nil
+	g.rng = nil
+	return g
+}
+
+func (g *Genetic) random() *rand.Rand {
+	if g.rng == nil {
+		if g.Config.Seed == 0 {
+			g.Config.Seed = time.Now().UnixNano()
+		}
+		g.src = &splitMix{state: uint64(g.Config.Seed)}
+		g.rng = rand.New(g.src)
+	}
+	return g.rng
+}
+
+// forks derives count independent streams from the run's generator, one
+// for every goroutine, so the results do not depend on scheduling.
+func (g *Genetic) forks(count int) []*rand.Rand {
+	rngs := make([]*rand.Rand, count)
+	for i := range rngs {
+		rngs[i] = rand.New(&splitMix{state: g.random().Uint64()})
+	}
+	return rngs
+}
+
+// SetSeed makes the CMA run reproducible like Genetic.SetSeed.
+func (c *CMA) SetSeed(seed int64) *CMA {
+	c.Config.Seed = seed
+	c.src = nil
+	c.rng = nil
+	return c
+}
+
+func (c *CMA) random() *rand.Rand {
+	if c.rng == nil {
+		if c.Config.Seed == 0 {
+			c.Config.Seed = time.Now().UnixNano()
+		}
+		c.src = &splitMix{state: uint64(c.Config.Seed)}
+		c.rng = rand.New(c.src)
+	}
+	return c.rng
+}
+
+func (c *CMA) forks(count int) []*rand.Rand {
+	rngs := make([]*rand.Rand, count)
+	for i := range rngs {
+		rngs[i] = rand.New(&splitMix{state: c.random().Uint64()})
+	}
+	return rngs
+}
+
+// randRange returns an int in [min, max).
+func randRange(rng *rand.Rand, min, max int) int {
+	return min + rng.Intn(max-min)
+}
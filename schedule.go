@@ -0,0 +1,120 @@
+package neuro
+
+import "math"
+
+// Schedule gives the learning rate for the next update. Train asks it
+// before every update with the base rate set by LRate, the epoch and update
+// counters and the mean error of the previous epoch (NaN before the first
+// epoch is over).
+type Schedule interface {
+	Rate(base float64, epoch, step int, loss float64) float64
+}
+
+// StepDecay multiplies the rate by Gamma every Every epochs.
+type StepDecay struct {
+	Every int
+	Gamma float64
+}
+
+func (s StepDecay) Rate(base float64, epoch, step int, loss float64) float64 {
+	if s.Every < 1 {
+		return base
+	}
+	return base * math.Pow(s.Gamma, float64(epoch/s.Every))
+}
+
+// ExpDecay multiplies the rate by Gamma every epoch.
+type ExpDecay struct {
+	Gamma float64
+}
+
+func (s ExpDecay) Rate(base float64, epoch, step int, loss float64) float64 {
+	return base * math.Pow(s.Gamma, float64(epoch))
+}
+
+// CosineRestarts anneals the rate from base down to Min over Period epochs
+// and then restarts, every next period being Mult times longer.
+type CosineRestarts struct {
+	Period int
+	Mult   float64
+	Min    float64
+}
+
+func (s CosineRestarts) Rate(base float64, epoch, step int, loss float64) float64 {
+	if s.Period < 1 {
+		return base
+	}
+	mult := math.Max(s.Mult, 1)
+	cur, period := float64(epoch), float64(s.Period)
+	for cur >= period {
+		cur -= period
+		period *= mult
+	}
+	return s.Min + (base-s.Min)*(1+math.Cos(math.Pi*cur/period))/2
+}
+
+// Warmup raises the rate linearly over the first Steps updates and then
+// hands over to Then, or keeps the base rate when Then is nil.
+type Warmup struct {
+	Steps int
+	Then  Schedule
+}
+
+func (s Warmup) Rate(base float64, epoch, step int, loss float64) float64 {
+	if step < s.Steps {
+		return base * float64(step+1) / float64(s.Steps)
+	}
+	if s.Then != nil {
+		return s.Then.Rate(base, epoch, step, loss)
+	}
+	return base
+}
+
+// ReduceOnPlateau multiplies the rate by Factor when the epoch error has
+// not improved by MinDelta for Patience epochs, never going below Min. It
+// keeps state, so use it as a pointer and one per net.
+type ReduceOnPlateau struct {
+	Factor   float64
+	Patience int
+	MinDelta float64
+	Min      float64
+
+	best  float64
+	wait  int
+	scale float64
+	epoch int
+}
+
+func (s *ReduceOnPlateau) Rate(base float64, epoch, step int, loss float64) float64 {
+	if s.scale == 0 {
+		s.scale = 1
+		s.best = math.Inf(1)
+		s.epoch = -1
+	}
+	if epoch != s.epoch && !math.IsNaN(loss) {
+		s.epoch = epoch
+		if loss < s.best-s.MinDelta {
+			s.best = loss
+			s.wait = 0
+		} else {
+			s.wait++
+		}
+		if s.wait > 0 && s.wait >= s.Patience {
+			s.scale *= orDefault(s.Factor, 0.1)
+			s.wait = 0
+		}
+	}
+	return math.Max(base*s.scale, s.Min)
+}
+
+func (n *NetPerc) SetSchedule(s Schedule) *NetPerc {
+	n.Schedule = s
+	return n
+}
+
+func (n *NetPerc) rate() float64 {
+	if n.Schedule == nil {
+		return n.LearnRate
+	}
+	return n.Schedule.Rate(n.LearnRate, n.Epoch, n.Optim.Step, n.lastLoss)
+}
@@ -0,0 +1,24 @@
+package neuro
+
+import "testing"
+
+func TestReduceOnPlateauPatience(t *testing.T) {
+	s := &ReduceOnPlateau{Factor: 0.5, Patience: 3}
+	losses := []float64{1, 0.9, 0.9, 0.9, 0.9, 0.9, 0.9, 0.9}
+	// after the improvement in epoch 1 the rate is cut by the third epoch
+	// without one, and three epochs later again
+	want := []float64{1, 1, 1, 1, 0.5, 0.5, 0.5, 0.25}
+	for epoch, loss := range losses {
+		if got := s.Rate(1, epoch, 0, loss); got != want[epoch] {
+			t.Errorf("epoch %d: rate %g, want %g", epoch, got, want[epoch])
+		}
+	}
+
+	every := &ReduceOnPlateau{Factor: 0.5}
+	if got := every.Rate(1, 0, 0, 1); got != 1 {
+		t.Errorf("first epoch without patience: rate %g, want 1", got)
+	}
+	if got := every.Rate(1, 1, 0, 1); got != 0.5 {
+		t.Errorf("epoch without improvement and patience: rate %g, want 0.5", got)
+	}
+}
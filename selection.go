@@ -0,0 +1,251 @@
+package neuro
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Selection picks count parents out of the survivors. fitness has one value
+// per survivor, higher is better, and the survivors are sorted best first.
+type Selection interface {
+	Name() string
+	Select(rng *rand.Rand, fitness []float64, count int) []int
+}
+
+// TruncationSelection picks parents uniformly among the survivors, so the
+// selection pressure comes from LastBest alone.
+type TruncationSelection struct{}
+
+func (TruncationSelection) Name() string { return "truncation" }
+
+func (TruncationSelection) Select(rng *rand.Rand, fitness []float64, count int) []int {
+	res := make([]int, count)
+	for i := range res {
+		res[i] = rng.Intn(len(fitness))
+	}
+	return res
+}
+
+// TournamentSelection picks the fittest of Size random survivors, two by
+// default, for every parent.
+type TournamentSelection struct {
+	Size int
+}
+
+func (s TournamentSelection) Name() string { return "tournament:" + strconv.Itoa(s.Size) }
+
+func (s TournamentSelection) Select(rng *rand.Rand, fitness []float64, count int) []int {
+	size := s.Size
+	if size < 1 {
+		size = 2
+	}
+	res := make([]int, count)
+	for i := range res {
+		best := rng.Intn(len(fitness))
+		for k := 1; k < size; k++ {
+			if c := rng.Intn(len(fitness)); fitter(fitness[c], fitness[best]) {
+				best = c
+			}
+		}
+		res[i] = best
+	}
+	return res
+}
+
+// RouletteSelection picks survivors with a probability proportional to
+// their fitness, shifted so the worst survivor has none.
+type RouletteSelection struct{}
+
+func (RouletteSelection) Name() string { return "roulette" }
+
+func (RouletteSelection) Select(rng *rand.Rand, fitness []float64, count int) []int {
+	cum := cumulative(proportional(fitness))
+	res := make([]int, count)
+	for i := range res {
+		res[i] = spin(cum, rng.Float64()*cum[len(cum)-1])
+	}
+	return res
+}
+
+// RankSelection is linear ranking: the chance of a survivor depends on its
+// place only, the best one gets Pressure times the average chance. Pressure
+// lies in (1, 2] and defaults to 1.5.
+type RankSelection struct {
+	Pressure float64
+}
+
+func (s RankSelection) Name() string { return "rank:" + strconv.FormatFloat(s.Pressure, 'g', -1, 64) }
+
+func (s RankSelection) Select(rng *rand.Rand, fitness []float64, count int) []int {
+	sp := math.Min(math.Max(orDefault(s.Pressure, 1.5), 1), 2)
+	size := float64(len(fitness))
+	weights := make([]float64, len(fitness))
+	for place, i := range ranking(fitness) {
+		weights[i] = 2 - sp
+		if size > 1 {
+			weights[i] += 2 * (sp - 1) * (size - 1 - float64(place)) / (size - 1)
+		}
+	}
+	cum := cumulative(weights)
+	res := make([]int, count)
+	for i := range res {
+		res[i] = spin(cum, rng.Float64()*cum[len(cum)-1])
+	}
+	return res
+}
+
+// SUSSelection is stochastic universal sampling: fitness proportional like
+// roulette, but all parents come from one spin with evenly spaced pointers,
+// so the picks follow the expected counts closely.
+type SUSSelection struct{}
+
+func (SUSSelection) Name() string { return "sus" }
+
+func (SUSSelection) Select(rng *rand.Rand, fitness []float64, count int) []int {
+	cum := cumulative(proportional(fitness))
+	res := make([]int, count)
+	if count == 0 {
+		return res
+	}
+	step := cum[len(cum)-1] / float64(count)
+	start := rng.Float64() * step
+	for i := range res {
+		res[i] = spin(cum, start+float64(i)*step)
+	}
+	rng.Shuffle(len(res), func(i, j int) {
+		res[i], res[j] = res[j], res[i]
+	})
+	return res
+}
+
+// fitter reports whether a beats b, NaN loses against everything.
+func fitter(a, b float64) bool {
+	return a > b || (math.IsNaN(b) && !math.IsNaN(a))
+}
+
+// ranking returns the indexes of fitness from best to worst.
+func ranking(fitness []float64) []int {
+	order := make([]int, len(fitness))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return fitter(fitness[order[i]], fitness[order[j]])
+	})
+	return order
+}
+
+// proportional turns fitness into non negative weights. When no survivor
+// stands out they all get the same weight.
+func proportional(fitness []float64) []float64 {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, f := range fitness {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			continue
+		}
+		min, max = math.Min(min, f), math.Max(max, f)
+	}
+	weights := make([]float64, len(fitness))
+	for i, f := range fitness {
+		switch {
+		case !(max > min):
+			weights[i] = 1
+		case math.IsInf(f, 1):
+			weights[i] = max - min
+		case math.IsNaN(f) || math.IsInf(f, -1):
+			weights[i] = 0
+		default:
+			weights[i] = f - min
+		}
+	}
+	return weights
+}
+
+func cumulative(weights []float64) []float64 {
+	cum := make([]float64, len(weights))
+	var sum float64
+	for i, w := range weights {
+		sum += w
+		cum[i] = sum
+	}
+	return cum
+}
+
+// spin returns the index whose slice of the cumulative weights holds x.
+func spin(cum []float64, x float64) int {
+	i := sort.Search(len(cum), func(i int) bool { return cum[i] > x })
+	if i == len(cum) {
+		i = len(cum) - 1
+	}
+	return i
+}
+
+func SelectionByName(name string) (Selection, error) {
+	base, param := name, ""
+	if i := strings.Index(name, ":"); i >= 0 {
+		base, param = name[:i], name[i+1:]
+	}
+	switch base {
+	case "", "truncation":
+		return TruncationSelection{}, nil
+	case "tournament":
+		s := TournamentSelection{Size: 2}
+		if param != "" {
+			size, err := strconv.Atoi(param)
+			if err != nil {
+				return nil, err
+			}
+			s.Size = size
+		}
+		return s, nil
+	case "roulette":
+		return RouletteSelection{}, nil
+	case "rank":
+		s := RankSelection{Pressure: 1.5}
+		if param != "" {
+			sp, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				return nil, err
+			}
+			s.Pressure = sp
+		}
+		return s, nil
+	case "sus":
+		return SUSSelection{}, nil
+	}
+	return nil, fmt.Errorf("unknown selection %q", name)
+}
+
+// SetSelection picks how parents are chosen among the survivors, uniformly
+// without one.
+func (g *Genetic) SetSelection(s Selection) *Genetic {
+	g.Config.Selection = ""
+	if s != nil {
+		g.Config.Selection = s.Name()
+	}
+	g.sel = s
+	return g
+}
+
+func (g *Genetic) selection() Selection {
+	if g.sel == nil {
+		g.sel, _ = SelectionByName(g.Config.Selection)
+		if g.sel == nil {
+			g.sel = TruncationSelection{}
+		}
+	}
+	return g.sel
+}
+
+// scores is the fitness of the survivors that selection works on.
+func (g *Genetic) scores() []float64 {
+	fitness := make([]float64, len(g.Nets))
+	for i, n := range g.Nets {
+		fitness[i] = n.Score
+	}
+	return fitness
+}
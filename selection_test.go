@@ -0,0 +1,98 @@
+package neuro
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSelection(t *testing.T) {
+	fitness := []float64{5, 4, 3, 2, 1, math.NaN()}
+	cases := []struct {
+		sel Selection
+		// favours says whether fitter survivors are picked more often
+		favours bool
+	}{
+		{TruncationSelection{}, false},
+		{TournamentSelection{Size: 3}, true},
+		{RouletteSelection{}, true},
+		{RankSelection{Pressure: 2}, true},
+		{SUSSelection{}, true},
+	}
+	for _, c := range cases {
+		name := c.sel.Name()
+		rng := rand.New(rand.NewSource(1))
+		before := append([]float64(nil), fitness...)
+		picks := c.sel.Select(rng, fitness, 6000)
+		if len(picks) != 6000 {
+			t.Errorf("%s: %d picks, want 6000", name, len(picks))
+			continue
+		}
+		counts := make([]int, len(fitness))
+		for _, i := range picks {
+			if i < 0 || i >= len(fitness) {
+				t.Fatalf("%s: index %d out of range", name, i)
+			}
+			counts[i]++
+		}
+		for i := 0; i < len(fitness)-1; i++ {
+			if fitness[i] != before[i] {
+				t.Errorf("%s: changed the fitness", name)
+			}
+		}
+		if counts[0] == 0 {
+			t.Errorf("%s: never picked the best survivor", name)
+		}
+		if c.favours && (counts[0] <= counts[4] || counts[0] <= counts[5]) {
+			t.Errorf("%s: best picked %d times, worst %d, NaN %d", name, counts[0], counts[4], counts[5])
+		}
+		if byName, err := SelectionByName(name); err != nil || byName != c.sel {
+			t.Errorf("%s: by name gives %v, %v", name, byName, err)
+		}
+	}
+}
+
+func TestSelectionByNameErrors(t *testing.T) {
+	for _, name := range []string{"best", "tournament:x", "rank:x"} {
+		if _, err := SelectionByName(name); err == nil {
+			t.Errorf("%q: no error", name)
+		}
+	}
+}
+
+func TestSelectionFlatFitness(t *testing.T) {
+	flat := []float64{1, 1, 1}
+	for _, sel := range []Selection{RouletteSelection{}, SUSSelection{}, RankSelection{}} {
+		seen := map[int]bool{}
+		for _, i := range sel.Select(rand.New(rand.NewSource(1)), flat, 300) {
+			seen[i] = true
+		}
+		if len(seen) != len(flat) {
+			t.Errorf("%s: picked %d of %d equal survivors", sel.Name(), len(seen), len(flat))
+		}
+	}
+}
+
+func TestElitism(t *testing.T) {
+	conf := testConf()
+	conf.Elitism = 2
+	g := testGenetic(3, conf)
+	g.SetSelection(TournamentSelection{Size: 2})
+	for i := 0; i < 3; i++ {
+		g.Train(true)
+		elite := []*NetPerc{g.Nets[0], g.Nets[1]}
+		g.mutate(g.scores())
+		// mutate refills the population from the survivors plus one
+		if len(g.Nets) != conf.Population+1 {
+			t.Fatalf("population of %d, want %d", len(g.Nets), conf.Population+1)
+		}
+		if g.Nets[0] != elite[0] || g.Nets[1] != elite[1] {
+			t.Fatal("the elite was not kept")
+		}
+		for _, n := range g.Nets[2:] {
+			if n == elite[0] || n == elite[1] {
+				t.Fatal("an elite net was kept twice")
+			}
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package neuro
+
+import (
+	"context"
+	"math"
+)
+
+// FullBatch as TrainConf.BatchSize averages the gradient over the whole
+// data set before every update.
+const FullBatch = -1
+
+// TrainConf controls how Train walks the data. The zero value keeps plain
+// online learning: one update per sample in data order.
+//
+// Validation data is either given directly or ValidSplit of n.Data is held
+// out from its end, so time ordered data is validated on the latest rows.
+// With Patience set Train stops once the validation error has not improved
+// by MinDelta for that many epochs, RestoreBest puts back the weights of the
+// best validation epoch.
+type TrainConf struct {
+	BatchSize   int         `json:"batch_size"`
+	Accumulate  int         `json:"accumulate"`
+	Shuffle     bool        `json:"shuffle"`
+	Validation  []DataTeach `json:"-"`
+	ValidSplit  float64     `json:"valid_split"`
+	Patience    int         `json:"patience"`
+	MinDelta    float64     `json:"min_delta"`
+	RestoreBest bool        `json:"restore_best"`
+}
+
+func (n *NetPerc) SetTrainConf(conf TrainConf) *NetPerc {
+	n.TrainConf = conf
+	return n
+}
+
+// updateEvery is the number of samples whose gradients are averaged into
+// one weight update.
+func (n *NetPerc) updateEvery() int {
+	batch := n.TrainConf.BatchSize
+	if batch == FullBatch || batch > len(n.Data) {
+		batch = len(n.Data)
+	}
+	if batch < 1 {
+		batch = 1
+	}
+	if n.TrainConf.Accumulate > 1 {
+		batch *= n.TrainConf.Accumulate
+	}
+	return batch
+}
+
+func (n *NetPerc) epochOrder() []int {
+	if len(n.order) != len(n.Data) {
+		n.order = make([]int, len(n.Data))
+	}
+	for i := range n.order {
+		n.order[i] = i
+	}
+	if n.TrainConf.Shuffle {
+		n.random().Shuffle(len(n.order), func(i, j int) {
+			n.order[i], n.order[j] = n.order[j], n.order[i]
+		})
+	}
+	return n.order
+}
+
+func (n *NetPerc) trainEpoch(ctx context.Context) error {
+	n.lastLoss = math.NaN()
+	if n.Epoch > 0 {
+		n.lastLoss = n.Error
+	}
+	every := n.updateEvery()
+	var count int
+	for _, ind := range n.epochOrder() {
+		if err := ctx.Err(); err != nil {
+			for _, l := range n.Dense {
+				l.clearGrads()
+			}
+			return err
+		}
+		n.CurrInd = ind
+		// ===========================
+		n.forwardPass()
+		// ===========================
+		n.calcError()
+		// ===========================
+		n.backPropogation()
+		// ===========================
+		count++
+		if count == every {
+			if err := n.applyGrads(count); err != nil {
+				return err
+			}
+			count = 0
+		}
+	}
+	if count > 0 {
+		if err := n.applyGrads(count); err != nil {
+			return err
+		}
+	}
+	n.CurrInd = 0
+	n.Epoch++
+	return nil
+}
+
+// splitValidation returns the validation set and the data to train on.
+func (n *NetPerc) splitValidation() ([]DataTeach, []DataTeach) {
+	conf := n.TrainConf
+	if len(conf.Validation) > 0 {
+		return conf.Validation, n.Data
+	}
+	if conf.ValidSplit <= 0 || conf.ValidSplit >= 1 {
+		return nil, n.Data
+	}
+	cut := len(n.Data) - int(float64(len(n.Data))*conf.ValidSplit)
+	if cut < 1 || cut == len(n.Data) {
+		return nil, n.Data
+	}
+	return n.Data[cut:], n.Data[:cut]
+}
+
+func (n *NetPerc) calcDataSetError(data []DataTeach) float64 {
+	var sumErr float64
+	for _, dt := range data {
+		n.forward(dt.Inputs)
+		fl := n.outputError(dt.Outputs)
+		if math.IsNaN(fl) {
+			sumErr += 1
+		} else {
+			sumErr += fl
+		}
+	}
+	return sumErr / float64(len(data))
+}
+
+type earlyStop struct {
+	best    float64
+	wait    int
+	weights [][]float64
+	biases  [][]float64
+}
+
+// check records the validation error of the finished epoch and reports
+// whether training should stop.
+func (s *earlyStop) check(n *NetPerc) bool {
+	if n.ValidError < s.best-n.TrainConf.MinDelta {
+		s.best = n.ValidError
+		s.wait = 0
+		n.BestEpoch = n.Epoch
+		if n.TrainConf.RestoreBest {
+			s.save(n)
+		}
+		return false
+	}
+	s.wait++
+	return n.TrainConf.Patience > 0 && s.wait >= n.TrainConf.Patience
+}
+
+func (s *earlyStop) save(n *NetPerc) {
+	if len(s.weights) != len(n.Dense) {
+		s.weights = make([][]float64, len(n.Dense))
+		s.biases = make([][]float64, len(n.Dense))
+	}
+	for i, l := range n.Dense {
+		s.weights[i] = append(s.weights[i][:0], l.Weights...)
+		s.biases[i] = append(s.biases[i][:0], l.Biases...)
+	}
+}
+
+func (s *earlyStop) restore(n *NetPerc) {
+	if len(s.weights) != len(n.Dense) {
+		return
+	}
+	for i, l := range n.Dense {
+		copy(l.Weights, s.weights[i])
+		copy(l.Biases, s.biases[i])
+	}
+	n.ValidError = s.best
+}
+
+func (n *NetPerc) applyGrads(count int) error {
+	opt := n.optimizer()
+	n.curRate = n.rate()
+	n.Optim.Step++
+	for _, l := range n.Dense {
+		l.apply(opt, n.curRate, count, n.Optim.Step)
+	}
+	return n.emitBatch(BatchMetrics{Epoch: n.Epoch, Step: n.Optim.Step, Size: count, Rate: n.curRate})
+}
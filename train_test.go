@@ -0,0 +1,172 @@
+package neuro
+
+import (
+	"math"
+	"sort"
+	"testing"
+)
+
+func TestUpdateEvery(t *testing.T) {
+	cases := []struct {
+		conf TrainConf
+		want int
+	}{
+		{TrainConf{}, 1},
+		{TrainConf{BatchSize: 4}, 4},
+		{TrainConf{BatchSize: 40}, 10},
+		{TrainConf{BatchSize: FullBatch}, 10},
+		{TrainConf{BatchSize: 3, Accumulate: 2}, 6},
+		{TrainConf{Accumulate: 3}, 3},
+	}
+	n := InitNetPerc(1, 3).CreateNet(benchSet(10, 2, 2), 1)
+	for _, c := range cases {
+		if got := n.SetTrainConf(c.conf).updateEvery(); got != c.want {
+			t.Errorf("%+v: updates every %d samples, want %d", c.conf, got, c.want)
+		}
+	}
+}
+
+func TestEpochOrder(t *testing.T) {
+	n := InitNetPerc(1, 3).CreateNet(benchSet(50, 2, 2), 1)
+	order := append([]int(nil), n.epochOrder()...)
+	for i, ind := range order {
+		if ind != i {
+			t.Fatalf("order without shuffle starts %v", order[:i+1])
+		}
+	}
+	n.SetTrainConf(TrainConf{Shuffle: true})
+	order = append(order[:0], n.epochOrder()...)
+	moved := false
+	for i, ind := range order {
+		moved = moved || ind != i
+	}
+	sort.Ints(order)
+	for i, ind := range order {
+		if ind != i {
+			t.Fatalf("shuffled order is not a permutation: %v", order)
+		}
+	}
+	if !moved {
+		t.Fatal("shuffle kept the data order")
+	}
+}
+
+// trainedWeights runs one epoch on a copy of n with conf.
+func trainedWeights(n *NetPerc, conf TrainConf) [][]float64 {
+	c := n.Copy().SetTrainConf(conf)
+	c.TrainIters()
+	return snapshot(c)
+}
+
+func TestBatchModes(t *testing.T) {
+	n := InitNetPerc(1, 4).SetBias(true).SetWeight(-1, 1).LRate(0.5).CreateNet(benchSet(10, 3, 2), 1)
+	online := trainedWeights(n, TrainConf{})
+	batch := trainedWeights(n, TrainConf{BatchSize: 4})
+	if sameFloats(online, batch) {
+		t.Fatal("mini-batches gave the weights of online learning")
+	}
+	if !sameFloats(batch, trainedWeights(n, TrainConf{BatchSize: 2, Accumulate: 2})) {
+		t.Error("accumulating two batches of 2 differs from batches of 4")
+	}
+	full := trainedWeights(n, TrainConf{BatchSize: FullBatch})
+	if !sameFloats(full, trainedWeights(n, TrainConf{BatchSize: 10})) {
+		t.Error("a full batch differs from a batch of the data size")
+	}
+	shuffled := trainedWeights(n, TrainConf{BatchSize: FullBatch, Shuffle: true})
+	for i := range full {
+		for k := range full[i] {
+			if math.Abs(full[i][k]-shuffled[i][k]) > 1e-12 {
+				t.Fatalf("a full batch depends on the data order: %g and %g", full[i][k], shuffled[i][k])
+			}
+		}
+	}
+}
+
+func TestEarlyStopCheck(t *testing.T) {
+	n := &NetPerc{TrainConf: TrainConf{Patience: 2, MinDelta: 0.1}}
+	s := earlyStop{best: math.Inf(1)}
+	steps := []struct {
+		valid float64
+		stop  bool
+	}{
+		{1, false},
+		{0.95, false}, // not better by MinDelta
+		{0.85, false},
+		{0.8, false},
+		{0.79, true},
+	}
+	for i, st := range steps {
+		n.Epoch = i + 1
+		n.ValidError = st.valid
+		if got := s.check(n); got != st.stop {
+			t.Fatalf("epoch %d with error %g: stop %v, want %v", n.Epoch, st.valid, got, st.stop)
+		}
+	}
+	if s.best != 0.85 || n.BestEpoch != 3 {
+		t.Fatalf("best %g at epoch %d, want 0.85 at epoch 3", s.best, n.BestEpoch)
+	}
+}
+
+// fixWeights replaces the random weights of n by a fixed pattern.
+func fixWeights(n *NetPerc) *NetPerc {
+	for il, l := range n.Dense {
+		for k := range l.Weights {
+			l.Weights[k] = math.Sin(float64(il*100 + k))
+		}
+		for k := range l.Biases {
+			l.Biases[k] = math.Cos(float64(il*100 + k))
+		}
+	}
+	return n
+}
+
+// reversedSet is data whose targets work against training on data.
+func reversedSet(data []DataTeach) []DataTeach {
+	res := make([]DataTeach, len(data))
+	for i, dt := range data {
+		res[i].Inputs = dt.Inputs
+		for k := len(dt.Outputs) - 1; k >= 0; k-- {
+			res[i].Outputs = append(res[i].Outputs, dt.Outputs[k])
+		}
+	}
+	return res
+}
+
+func TestEarlyStopPatience(t *testing.T) {
+	data := benchSet(20, 3, 2)
+	n := fixWeights(InitNetPerc(1, 4).SetBias(true).LRate(0.5).CreateNet(data, 100))
+	n.SetTrainConf(TrainConf{Validation: reversedSet(data), Patience: 3})
+	n.Train()
+	if n.Epoch == 100 || n.Epoch != n.BestEpoch+3 {
+		t.Fatalf("stopped at epoch %d with the best at %d, want 3 epochs after it", n.Epoch, n.BestEpoch)
+	}
+}
+
+func TestRestoreBest(t *testing.T) {
+	data := benchSet(20, 3, 2)
+	valid := reversedSet(data)
+	n := fixWeights(InitNetPerc(1, 4).SetBias(true).LRate(0.5).CreateNet(data, 20))
+
+	c := n.Copy()
+	best := math.Inf(1)
+	var weights [][]float64
+	for i := 0; i < 20; i++ {
+		c.TrainIters()
+		if e := c.calcDataSetError(valid); e < best {
+			best = e
+			weights = snapshot(c)
+		}
+	}
+	if sameFloats(weights, snapshot(c)) {
+		t.Fatal("the last epoch is the best, nothing to restore")
+	}
+
+	n.SetTrainConf(TrainConf{Validation: valid, RestoreBest: true})
+	n.Train()
+	if !sameFloats(snapshot(n), weights) {
+		t.Error("Train did not restore the weights of the best epoch")
+	}
+	if n.ValidError != best {
+		t.Errorf("valid error %g, want the best %g", n.ValidError, best)
+	}
+}
@@ -1,11 +1,17 @@
 package neuro
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -39,17 +45,32 @@ const (
 )
 
 func GetData(period, last int, filename string) []DataTeach {
+	data, err := GetDataCtx(context.Background(), period, last, filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return data
+}
+
+// GetDataCtx is GetData that returns errors instead of exiting. When ctx is
+// done during the download the pages fetched so far are formed and
+// returned with ctx.Err(); they are not written to filename.
+func GetDataCtx(ctx context.Context, period, last int, filename string) ([]DataTeach, error) {
 	var dataList []*KLine
 	if bts, err := ioutil.ReadFile(filename); err != nil {
 		for i := 1; i <= period; i++ {
 			st := time.Now().Add(time.Hour * (1000 * time.Duration(i)) * -1)
 			var list [][]interface{}
-			if err := request(formUrl(st), &list); err != nil {
-				log.Fatal("error request:", err)
+			if err := request(ctx, formUrl(st), &list); err != nil {
+				if ctx.Err() != nil {
+					sorted(dataList)
+					return prepareData(dataList, last), ctx.Err()
+				}
+				return nil, fmt.Errorf("error request: %w", err)
 			}
 			klines, err := formatData(list)
 			if err != nil {
-				log.Fatal("error format:", err)
+				return nil, fmt.Errorf("error format: %w", err)
 			}
 			dataList = append(dataList, klines...)
 			log.Println("iterate parse: ", i)
@@ -57,7 +78,7 @@ func GetData(period, last int, filename string) []DataTeach {
 		sorted(dataList)
 		bts, err := json.Marshal(dataList)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 		if err := ioutil.WriteFile(filename, bts, 0644); err != nil {
 			log.Println(err)
@@ -66,8 +87,15 @@ func GetData(period, last int, filename string) []DataTeach {
 		json.Unmarshal(bts, &dataList)
 	}
 
+	return prepareData(dataList, last), nil
+}
+
+func prepareData(dataList []*KLine, last int) []DataTeach {
 	setPercent(dataList)
 	setArrPriv(last, dataList)
+	if len(dataList) <= last+1 {
+		return nil
+	}
 	dataList = dataList[last+1:]
 
 	return formedData(dataList)
@@ -148,11 +176,16 @@ func formUrl(start time.Time) string {
 	return strings.Replace(urlBinance, "{$START}", strconv.FormatInt(start.UnixMilli(), 10), -1)
 }
 
-func request(str string, in interface{}) error {
-	resp, er := http.Get(str)
+func request(ctx context.Context, str string, in interface{}) error {
+	req, er := http.NewRequestWithContext(ctx, http.MethodGet, str, nil)
 	if er != nil {
 		return er
 	}
+	resp, er := http.DefaultClient.Do(req)
+	if er != nil {
+		return er
+	}
+	defer resp.Body.Close()
 	bts, er := ioutil.ReadAll(resp.Body)
 	if er != nil {
 		return er
@@ -193,3 +226,43 @@ func formatData(ins [][]interface{}) ([]*KLine, error) {
 	}
 	return list, nil
 }
+
+// writeFile writes fileName through a synced temporary file in the same
+// directory and renames it over the old one, readers see either the old or
+// the new content but never a partial write.
+func writeFile(fileName string, write func(w io.Writer) error) (err error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(fileName), filepath.Base(fileName)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	bw := bufio.NewWriter(tmp)
+	if err = write(bw); err != nil {
+		return err
+	}
+	if err = bw.Flush(); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(0644); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), fileName); err != nil {
+		return err
+	}
+	if dir, err := os.Open(filepath.Dir(fileName)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package neuro
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetDataCtxCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	file := filepath.Join(t.TempDir(), "missing.json")
+	data, err := GetDataCtx(ctx, 3, 5, file)
+	if err != context.Canceled {
+		t.Fatalf("cancelled download returned %v", err)
+	}
+	if data != nil {
+		t.Fatalf("cancelled download gave %d rows", len(data))
+	}
+}
+
+func TestWriteFileKeepsOld(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "net.json")
+	write := func(content string, fail error) error {
+		return writeFile(fileName, func(w io.Writer) error {
+			if _, err := io.WriteString(w, content); err != nil {
+				return err
+			}
+			return fail
+		})
+	}
+	if err := write("old", nil); err != nil {
+		t.Fatal(err)
+	}
+	boom := errors.New("boom")
+	if err := write("new", boom); err != boom {
+		t.Fatalf("failed write returned %v", err)
+	}
+	bts, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bts) != "old" {
+		t.Fatalf("file holds %q after a failed write", bts)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("%d files left behind", len(files))
+	}
+}